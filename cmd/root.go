@@ -6,7 +6,6 @@ import (
 	"os/signal"
 	"path"
 	"strconv"
-	"strings"
 	"sync"
 	"syscall"
 	"time"
@@ -15,6 +14,7 @@ import (
 
 	"github.com/fsnotify/fsnotify"
 	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cast"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
@@ -37,11 +37,18 @@ func init() {
 	RootCmd.Flags().Uint8("mem", 1, "memory metrics level")
 	RootCmd.Flags().Uint8("disk", 1, "disk metrics level")
 	RootCmd.Flags().Uint8("net", 1, "network metrics level")
+	RootCmd.Flags().Uint8("netstat", 0, "tcp/udp stack metrics level")
+	RootCmd.Flags().Uint8("conntrack", 0, "conntrack metrics level")
+	RootCmd.Flags().Uint8("pressure", 0, "pressure stall information metrics level")
+	RootCmd.Flags().Uint8("cgroup", 0, "cgroup metrics level")
+	RootCmd.Flags().Uint8("proc", 0, "process groups metrics level")
+	RootCmd.Flags().Uint8("systemd", 0, "systemd units metrics level")
+	RootCmd.Flags().Uint8("hwmon", 0, "hardware sensors metrics level")
 	RootCmd.Flags().StringSlice("net-opts.interfaces", make([]string, 0), "give a filtering list of network interfaces to collect metrics on")
 	RootCmd.Flags().StringSlice("disk-opts.names", make([]string, 0), "give a filtering list of disks names to collect metrics on")
 	RootCmd.Flags().Uint64("period", 1000, "default collection period")
 	RootCmd.Flags().StringP("collectors", "c", "./collectors", "external collectors directory")
-	RootCmd.Flags().Uint64P("keep-for", "k", 3, "keep collectors data for the given number of fetch")
+	RootCmd.Flags().Uint64P("keep-for", "k", 3, "keep collectors data for the given number of runs")
 	RootCmd.Flags().String("format", "sensision", "the output global format of noderig")
 	RootCmd.Flags().String("separator", ".", "the class separator string")
 
@@ -97,8 +104,10 @@ func initConfig() {
 	if viper.IsSet("format") {
 		format := viper.GetString("format")
 
-		if format != "sensision" {
+		if _, ok := core.GetEncoder(format); ok {
 			core.Format = format
+		} else {
+			log.Warnf("Unknown format '%s', fallback to %s", format, core.Format)
 		}
 	}
 
@@ -114,15 +123,16 @@ func initConfig() {
 		labelsSet := viper.GetStringMapString("labels")
 
 		if len(labelsSet) > 0 {
+			core.DefaultLabels = core.Labels(labelsSet)
+		}
+	}
 
-			labelsSplices := make([]string, 0)
-
-			for key, value := range labelsSet {
-				labelsSplices = append(labelsSplices, core.ToLabels(key, value))
-			}
-
-			core.DefaultLabels = strings.Join(labelsSplices, ",")
+	if viper.IsSet("relabel") {
+		rules, err := relabelRules(viper.Get("relabel"))
+		if err != nil {
+			log.WithError(err).Fatal("Invalid relabel rules")
 		}
+		core.RelabelRules = rules
 	}
 
 	viper.WatchConfig()
@@ -174,13 +184,19 @@ func rootFn(cmd *cobra.Command, args []string) {
 
 	// Setup http
 	http.Handle("/metrics", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, enc, err := core.Negotiate(req.URL.Query().Get("format"), req.Header.Get("Accept"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
 		csMutex.Lock()
-		defer csMutex.Unlock()
-		for _, c := range cs {
-			_, err := w.Write(c.Metrics().Bytes())
-			if err != nil {
-				log.WithError(err).Error("cannot write metric into file")
-			}
+		samples := core.Gather(cs)
+		csMutex.Unlock()
+
+		w.Header().Set("Content-Type", enc.ContentType())
+		if err := enc.Encode(w, samples); err != nil {
+			log.WithError(err).Error("cannot write metrics to client")
 		}
 	}))
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
@@ -200,6 +216,14 @@ func rootFn(cmd *cobra.Command, args []string) {
 
 	if viper.IsSet("flushPath") {
 		flushPath := viper.GetString("flushPath")
+
+		// JSON flush files are written as JSON Lines
+		flushFormat := core.Format
+		if flushFormat == "json" {
+			flushFormat = "jsonl"
+		}
+		enc, _ := core.GetEncoder(flushFormat)
+
 		ticker := time.NewTicker(time.Duration(viper.GetInt("flushPeriod")) * time.Millisecond)
 		go func() {
 			for range ticker.C {
@@ -208,17 +232,17 @@ func rootFn(cmd *cobra.Command, args []string) {
 				file, err := os.Create(path + ".tmp")
 				if err != nil {
 					log.Errorf("Flush failed: %v", err)
+					continue
 				}
 
 				csMutex.Lock()
-				for _, c := range cs {
-					_, err := file.Write(c.Metrics().Bytes())
-					if err != nil {
-						log.WithError(err).Error("Cannot write metric into file")
-					}
-				}
+				samples := core.Gather(cs)
 				csMutex.Unlock()
 
+				if err := enc.Encode(file, samples); err != nil {
+					log.WithError(err).Error("Cannot write metric into file")
+				}
+
 				if err := file.Close(); err != nil {
 					log.WithError(err).Error("Cannot close flush file")
 				}
@@ -234,6 +258,10 @@ func rootFn(cmd *cobra.Command, args []string) {
 		log.Info("Flush routine started")
 	}
 
+	for name, s := range getSinks() {
+		startSink(name, s)
+	}
+
 	log.Info("Started")
 
 	if viper.GetString("listen") != "none" {
@@ -251,14 +279,42 @@ func rootFn(cmd *cobra.Command, args []string) {
 	}
 }
 
+// relabelRules builds the relabeling rules from the relabel configuration list
+func relabelRules(conf interface{}) ([]*core.RelabelRule, error) {
+	var rules []*core.RelabelRule
+	for i, item := range cast.ToSlice(conf) {
+		opts := cast.ToStringMap(item)
+
+		cfg := core.RelabelConfig{
+			SourceLabels: cast.ToStringSlice(opts["source_labels"]),
+			Separator:    cast.ToString(opts["separator"]),
+			Regex:        cast.ToString(opts["regex"]),
+			TargetLabel:  cast.ToString(opts["target_label"]),
+			Modulus:      uint64(cast.ToInt64(opts["modulus"])),
+			Action:       cast.ToString(opts["action"]),
+		}
+		if replacement, ok := opts["replacement"]; ok {
+			r := cast.ToString(replacement)
+			cfg.Replacement = &r
+		}
+
+		rule, err := core.NewRelabelRule(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %v", i, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
 func getCollectors() []core.Collector {
 	// Build collectors
 	var cs []core.Collector
 
-	cpu := collectors.NewCPU(uint(viper.GetInt("period")), uint8(viper.GetInt("cpu")), viper.GetStringSlice("cpu-mods"))
+	cpu := collectors.NewCPU(uint(viper.GetInt("period")), uint8(viper.GetInt("cpu")), viper.GetStringSlice("cpu-mods"), viper.Get("cpu-opts"))
 	cs = append(cs, cpu)
 
-	mem := collectors.NewMemory(uint(viper.GetInt("period")), uint8(viper.GetInt("mem")))
+	mem := collectors.NewMemory(uint(viper.GetInt("period")), uint8(viper.GetInt("mem")), viper.Get("mem-opts"))
 	cs = append(cs, mem)
 
 	load := collectors.NewLoad(uint(viper.GetInt("period")), uint8(viper.GetInt("load")))
@@ -267,9 +323,30 @@ func getCollectors() []core.Collector {
 	net := collectors.NewNet(uint(viper.GetInt("period")), uint8(viper.GetInt("net")), viper.Get("net-opts"))
 	cs = append(cs, net)
 
+	netstat := collectors.NewNetstat(uint(viper.GetInt("period")), uint8(viper.GetInt("netstat")), viper.Get("netstat-opts"))
+	cs = append(cs, netstat)
+
+	conntrack := collectors.NewConntrack(uint(viper.GetInt("period")), uint8(viper.GetInt("conntrack")), viper.Get("conntrack-opts"))
+	cs = append(cs, conntrack)
+
 	disk := collectors.NewDisk(uint(viper.GetInt("period")), uint8(viper.GetInt("disk")), viper.Get("disk-opts"))
 	cs = append(cs, disk)
 
+	pressure := collectors.NewPressure(uint(viper.GetInt("period")), uint8(viper.GetInt("pressure")), viper.Get("pressure-opts"))
+	cs = append(cs, pressure)
+
+	cgroup := collectors.NewCgroup(uint(viper.GetInt("period")), uint8(viper.GetInt("cgroup")), viper.Get("cgroup-opts"))
+	cs = append(cs, cgroup)
+
+	proc := collectors.NewProc(uint(viper.GetInt("period")), uint8(viper.GetInt("proc")), viper.Get("proc-opts"))
+	cs = append(cs, proc)
+
+	systemd := collectors.NewSystemd(uint(viper.GetInt("period")), uint8(viper.GetInt("systemd")), viper.Get("systemd-opts"))
+	cs = append(cs, systemd)
+
+	hwmon := collectors.NewHwmon(uint(viper.GetInt("period")), uint8(viper.GetInt("hwmon")), viper.Get("hwmon-opts"))
+	cs = append(cs, hwmon)
+
 	// Load external collectors
 	cpath := viper.GetString("collectors")
 	cdir, err := os.Open(cpath)
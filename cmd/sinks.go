@@ -0,0 +1,213 @@
+package cmd
+
+import (
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/shirou/gopsutil/host"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+
+	"github.com/ovh/noderig/core"
+	"github.com/ovh/noderig/sinks"
+)
+
+// Sinks defaults
+func init() {
+	viper.SetDefault("warp10.gzip", true)
+	viper.SetDefault("warp10.timeout", 10000)
+	viper.SetDefault("warp10.retries", 3)
+	viper.SetDefault("warp10.backoff", 1000)
+	viper.SetDefault("warp10.max-backoff", 30000)
+	viper.SetDefault("warp10.spool-size", 1000)
+
+	viper.SetDefault("remote-write.batch-size", 500)
+	viper.SetDefault("remote-write.timeout", 10000)
+	viper.SetDefault("remote-write.retries", 3)
+	viper.SetDefault("remote-write.backoff", 1000)
+	viper.SetDefault("remote-write.max-backoff", 30000)
+
+	viper.SetDefault("influx.gzip", true)
+	viper.SetDefault("influx.batch-size", 5000)
+	viper.SetDefault("influx.timeout", 10000)
+	viper.SetDefault("influx.retries", 3)
+	viper.SetDefault("influx.backoff", 1000)
+	viper.SetDefault("influx.max-backoff", 30000)
+
+	viper.SetDefault("graphite.protocol", "tcp")
+	viper.SetDefault("graphite.format", "plaintext")
+	viper.SetDefault("graphite.template", "{class}.{labels}")
+	viper.SetDefault("graphite.timeout", 5000)
+
+	viper.SetDefault("statsd.network", "udp")
+	viper.SetDefault("statsd.flavor", "dogstatsd")
+	viper.SetDefault("statsd.packet-size", 1432)
+	viper.SetDefault("statsd.timeout", 1000)
+
+	viper.SetDefault("otlp.protocol", "grpc")
+	viper.SetDefault("otlp.gzip", true)
+	viper.SetDefault("otlp.timeout", 10000)
+	viper.SetDefault("otlp.retries", 3)
+	viper.SetDefault("otlp.backoff", 1000)
+	viper.SetDefault("otlp.max-backoff", 30000)
+}
+
+// sink is a configured sink with its push period (ms)
+type sink struct {
+	core.Sink
+	period uint
+}
+
+func getSinks() map[string]sink {
+	res := make(map[string]sink)
+	flushPeriod := uint(viper.GetInt("flushPeriod"))
+
+	if viper.IsSet("warp10.endpoint") {
+		s, err := sinks.NewWarp10(sinks.Warp10Options{
+			Endpoint:  viper.GetString("warp10.endpoint"),
+			Token:     viper.GetString("warp10.token"),
+			Gzip:      viper.GetBool("warp10.gzip"),
+			Timeout:   uint(viper.GetInt("warp10.timeout")),
+			Retries:   viper.GetInt("warp10.retries"),
+			Backoff:   uint(viper.GetInt("warp10.backoff")),
+			MaxWait:   uint(viper.GetInt("warp10.max-backoff")),
+			Spool:     viper.GetString("warp10.spool"),
+			SpoolSize: viper.GetInt("warp10.spool-size"),
+		})
+		if err != nil {
+			log.WithError(err).Fatal("Cannot init the Warp10 sink")
+		}
+		res["Warp10"] = sink{s, flushPeriod}
+	}
+
+	if viper.IsSet("remote-write.url") {
+		s, err := sinks.NewRemoteWrite(sinks.RemoteWriteOptions{
+			URL:       viper.GetString("remote-write.url"),
+			Username:  viper.GetString("remote-write.username"),
+			Password:  viper.GetString("remote-write.password"),
+			Bearer:    viper.GetString("remote-write.bearer-token"),
+			BatchSize: viper.GetInt("remote-write.batch-size"),
+			Timeout:   uint(viper.GetInt("remote-write.timeout")),
+			Retries:   viper.GetInt("remote-write.retries"),
+			Backoff:   uint(viper.GetInt("remote-write.backoff")),
+			MaxWait:   uint(viper.GetInt("remote-write.max-backoff")),
+		})
+		if err != nil {
+			log.WithError(err).Fatal("Cannot init the remote_write sink")
+		}
+		res["RemoteWrite"] = sink{s, flushPeriod}
+	}
+
+	if viper.IsSet("influx.url") {
+		s, err := sinks.NewInflux(sinks.InfluxOptions{
+			URL:       viper.GetString("influx.url"),
+			Org:       viper.GetString("influx.org"),
+			Bucket:    viper.GetString("influx.bucket"),
+			Token:     viper.GetString("influx.token"),
+			Gzip:      viper.GetBool("influx.gzip"),
+			BatchSize: viper.GetInt("influx.batch-size"),
+			Timeout:   uint(viper.GetInt("influx.timeout")),
+			Retries:   viper.GetInt("influx.retries"),
+			Backoff:   uint(viper.GetInt("influx.backoff")),
+			MaxWait:   uint(viper.GetInt("influx.max-backoff")),
+		})
+		if err != nil {
+			log.WithError(err).Fatal("Cannot init the Influx sink")
+		}
+		res["Influx"] = sink{s, flushPeriod}
+	}
+
+	if viper.IsSet("graphite.address") {
+		s, err := sinks.NewGraphite(sinks.GraphiteOptions{
+			Address:  viper.GetString("graphite.address"),
+			Protocol: viper.GetString("graphite.protocol"),
+			Format:   viper.GetString("graphite.format"),
+			Template: viper.GetString("graphite.template"),
+			Timeout:  uint(viper.GetInt("graphite.timeout")),
+		})
+		if err != nil {
+			log.WithError(err).Fatal("Cannot init the Graphite sink")
+		}
+		res["Graphite"] = sink{s, flushPeriod}
+	}
+
+	if viper.IsSet("statsd.address") {
+		s, err := sinks.NewStatsd(sinks.StatsdOptions{
+			Network:    viper.GetString("statsd.network"),
+			Address:    viper.GetString("statsd.address"),
+			Flavor:     viper.GetString("statsd.flavor"),
+			Prefix:     viper.GetString("statsd.prefix"),
+			PacketSize: viper.GetInt("statsd.packet-size"),
+			Timeout:    uint(viper.GetInt("statsd.timeout")),
+		})
+		if err != nil {
+			log.WithError(err).Fatal("Cannot init the StatsD sink")
+		}
+
+		// emit on each collection tick
+		res["Statsd"] = sink{s, uint(viper.GetInt("period"))}
+	}
+
+	if viper.IsSet("otlp.endpoint") {
+		s, err := sinks.NewOTLP(sinks.OTLPOptions{
+			Endpoint: viper.GetString("otlp.endpoint"),
+			Protocol: viper.GetString("otlp.protocol"),
+			Insecure: viper.GetBool("otlp.insecure"),
+			Headers:  viper.GetStringMapString("otlp.headers"),
+			Gzip:     viper.GetBool("otlp.gzip"),
+			Resource: otlpResource(),
+			Version:  version,
+			Timeout:  uint(viper.GetInt("otlp.timeout")),
+			Retries:  viper.GetInt("otlp.retries"),
+			Backoff:  uint(viper.GetInt("otlp.backoff")),
+			MaxWait:  uint(viper.GetInt("otlp.max-backoff")),
+		})
+		if err != nil {
+			log.WithError(err).Fatal("Cannot init the OTLP sink")
+		}
+		res["OTLP"] = sink{s, flushPeriod}
+	}
+
+	return res
+}
+
+// otlpResource returns the host identity and the configured labels as OTLP resource attributes
+func otlpResource() core.Labels {
+	res := core.Labels{
+		"service.name":    "noderig",
+		"service.version": version,
+		"os.type":         runtime.GOOS,
+	}
+
+	if info, err := host.Info(); err == nil {
+		res["host.name"] = info.Hostname
+		if info.HostID != "" {
+			res["host.id"] = info.HostID
+		}
+	} else if hostname, err := os.Hostname(); err == nil {
+		res["host.name"] = hostname
+	}
+
+	for k, v := range core.DefaultLabels {
+		res[k] = v
+	}
+	return res
+}
+
+// startSink pushes the collected samples to the sink on each sink period
+func startSink(name string, s sink) {
+	ticker := time.NewTicker(time.Duration(s.period) * time.Millisecond)
+	go func() {
+		for range ticker.C {
+			csMutex.Lock()
+			samples := core.Gather(cs)
+			csMutex.Unlock()
+
+			if err := s.Push(samples); err != nil {
+				log.WithError(err).Errorf("[%s] push failed", name)
+			}
+		}
+	}()
+	log.Infof("%s sink started", name)
+}
@@ -0,0 +1,261 @@
+package collectors
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ovh/noderig/core"
+	log "github.com/sirupsen/logrus"
+)
+
+func init() {
+	core.Describe("os.cgroup.cpu.usage.us", core.Microseconds, "Cgroup cpu usage (us)")
+	core.Describe("os.cgroup.cpu.user.us", core.Microseconds, "Cgroup cpu user time (us)")
+	core.Describe("os.cgroup.cpu.system.us", core.Microseconds, "Cgroup cpu system time (us)")
+	core.Describe("os.cgroup.cpu.periods", core.NoUnit, "Cgroup cpu enforcement periods")
+	core.Describe("os.cgroup.cpu.throttled.periods", core.NoUnit, "Cgroup cpu throttled periods")
+	core.Describe("os.cgroup.cpu.throttled.us", core.Microseconds, "Cgroup cpu throttled time (us)")
+	core.Describe("os.cgroup.mem.current", core.Bytes, "Cgroup memory usage (bytes)")
+	core.Describe("os.cgroup.mem.max", core.Bytes, "Cgroup memory limit (bytes)")
+	core.Describe("os.cgroup.mem.events", core.NoUnit, "Cgroup memory events count")
+	core.Describe("os.cgroup.pids.current", core.NoUnit, "Cgroup processes count")
+	core.Describe("os.cgroup.pids.max", core.NoUnit, "Cgroup processes limit")
+	core.Describe("os.cgroup.io.bytes", core.Bytes, "Cgroup io count (bytes)")
+	core.Describe("os.cgroup.io.ops", core.NoUnit, "Cgroup io operations count")
+}
+
+// systemd unit types a cgroup can be named after
+var systemdUnitSuffixes = []string{".service", ".scope", ".slice"}
+
+// Cgroup collects cgroup v2 (unified hierarchy) resource metrics
+type Cgroup struct {
+	root  string
+	depth int
+	paths []string
+
+	mutex   sync.RWMutex
+	samples []core.Sample
+	rate    *core.Rate
+	level   uint8
+}
+
+// NewCgroup returns an initialized Cgroup collector.
+func NewCgroup(period uint, level uint8, opts interface{}) *Cgroup {
+	c := &Cgroup{
+		root:  stringOption(opts, "root", sysPath("fs", "cgroup")),
+		depth: intOption(opts, "depth", 2),
+		paths: stringsOption(opts, "paths"),
+		rate:  core.NewRate(counterMode(opts)),
+		level: level,
+	}
+
+	if level == 0 {
+		return c
+	}
+
+	if _, err := os.Stat(filepath.Join(c.root, "cgroup.controllers")); err != nil {
+		log.Warnf("No cgroup v2 hierarchy mounted on %s, cgroup collector disabled", c.root)
+		return c
+	}
+
+	tick := time.NewTicker(time.Duration(period) * time.Millisecond)
+	go func() {
+		for range tick.C {
+			if err := c.scrape(); err != nil {
+				log.Error(err)
+			}
+		}
+	}()
+
+	return c
+}
+
+// Samples delivers metrics.
+func (c *Cgroup) Samples() []core.Sample {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	return append([]core.Sample(nil), c.samples...)
+}
+
+func (c *Cgroup) scrape() error {
+	var groups []string
+	err := filepath.Walk(c.root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if path == c.root {
+				return err
+			}
+			return nil // cgroup removed while walking
+		}
+		if !info.IsDir() || path == c.root {
+			return nil
+		}
+
+		rel, err := filepath.Rel(c.root, path)
+		if err != nil {
+			return err
+		}
+		if c.depth > 0 && strings.Count(rel, string(filepath.Separator))+1 > c.depth {
+			return filepath.SkipDir
+		}
+
+		name := "/" + filepath.ToSlash(rel)
+		if c.paths == nil || stringInSlice(name, c.paths) {
+			groups = append(groups, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UnixNano() / 1000
+	var samples []core.Sample
+	for _, name := range groups {
+		samples = append(samples, c.scrapeGroup(now, name)...)
+	}
+
+	// protect consistency
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.samples = c.rate.Apply(samples)
+
+	return nil
+}
+
+// scrapeGroup reads the statistics of a cgroup, skipping the files of
+// disabled controllers.
+func (c *Cgroup) scrapeGroup(now int64, name string) []core.Sample {
+	dir := filepath.Join(c.root, filepath.FromSlash(name))
+	labels := cgroupLabels(name)
+	class := "os.cgroup"
+
+	var samples []core.Sample
+
+	cpu, _ := readFlatKeyed(filepath.Join(dir, "cpu.stat"))
+	if v, ok := cpu["usage_usec"]; ok {
+		samples = append(samples, core.NewCounter(now, class+".cpu.usage.us", labels, v))
+	}
+	if v, ok, _ := readUint(filepath.Join(dir, "memory.current")); ok {
+		samples = append(samples, core.NewGauge(now, class+".mem.current", labels, v))
+	}
+
+	if c.level > 1 {
+		for _, key := range cgroupCPUKeys {
+			if v, ok := cpu[key[0]]; ok {
+				samples = append(samples, core.NewCounter(now, class+key[1], labels, v))
+			}
+		}
+
+		if v, ok, _ := readUint(filepath.Join(dir, "memory.max")); ok {
+			samples = append(samples, core.NewGauge(now, class+".mem.max", labels, v))
+		}
+		events, _ := readFlatKeyed(filepath.Join(dir, "memory.events"))
+		for _, event := range sortedKeys(events) {
+			eventLabels := labels.Copy()
+			eventLabels["event"] = event
+			samples = append(samples, core.NewCounter(now, class+".mem.events", eventLabels, events[event]))
+		}
+
+		if v, ok, _ := readUint(filepath.Join(dir, "pids.current")); ok {
+			samples = append(samples, core.NewGauge(now, class+".pids.current", labels, v))
+		}
+		if v, ok, _ := readUint(filepath.Join(dir, "pids.max")); ok {
+			samples = append(samples, core.NewGauge(now, class+".pids.max", labels, v))
+		}
+	}
+
+	if c.level > 2 {
+		samples = append(samples, cgroupIO(now, class, labels, filepath.Join(dir, "io.stat"))...)
+	}
+
+	return samples
+}
+
+// cpu.stat keys and their class
+var cgroupCPUKeys = [][2]string{
+	{"user_usec", ".cpu.user.us"},
+	{"system_usec", ".cpu.system.us"},
+	{"nr_periods", ".cpu.periods"},
+	{"nr_throttled", ".cpu.throttled.periods"},
+	{"throttled_usec", ".cpu.throttled.us"},
+}
+
+// io.stat keys, by class and direction
+var cgroupIOKeys = map[string][2]string{
+	"rbytes": {".io.bytes", "read"},
+	"wbytes": {".io.bytes", "write"},
+	"dbytes": {".io.bytes", "discard"},
+	"rios":   {".io.ops", "read"},
+	"wios":   {".io.ops", "write"},
+	"dios":   {".io.ops", "discard"},
+}
+
+// cgroupIO reads an io.stat file, e.g.
+// 8:0 rbytes=1459200 wbytes=314773504 rios=192 wios=353 dbytes=0 dios=0
+func cgroupIO(now int64, class string, labels core.Labels, path string) []core.Sample {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var samples []core.Sample
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		device := blockDeviceName(fields[0])
+
+		for _, field := range fields[1:] {
+			kv := strings.SplitN(field, "=", 2)
+			key, ok := cgroupIOKeys[kv[0]]
+			if !ok || len(kv) != 2 {
+				continue
+			}
+			v, err := strconv.ParseUint(kv[1], 10, 64)
+			if err != nil {
+				continue
+			}
+
+			ioLabels := labels.Copy()
+			ioLabels["device"] = device
+			ioLabels["direction"] = key[1]
+			samples = append(samples, core.NewCounter(now, class+key[0], ioLabels, v))
+		}
+	}
+
+	return samples
+}
+
+// blockDeviceName resolves a major:minor block device number to its name
+func blockDeviceName(majorMinor string) string {
+	target, err := os.Readlink(sysPath("dev", "block", majorMinor))
+	if err != nil {
+		return majorMinor
+	}
+	return filepath.Base(target)
+}
+
+// cgroupLabels labels a cgroup by its path, and by its systemd unit when it
+// is named after one.
+func cgroupLabels(name string) core.Labels {
+	labels := core.Labels{"cgroup": name}
+
+	base := filepath.Base(name)
+	for _, suffix := range systemdUnitSuffixes {
+		if strings.HasSuffix(base, suffix) {
+			labels["unit"] = base
+			break
+		}
+	}
+	return labels
+}
@@ -0,0 +1,110 @@
+package collectors
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ovh/noderig/core"
+)
+
+func TestCgroupScrape(t *testing.T) {
+	root, cleanup := fakeTree(t, map[string]string{
+		"fs/cgroup/cgroup.controllers": "cpu memory pids io\n",
+		"fs/cgroup/system.slice/cpu.stat": "usage_usec 3000\nuser_usec 2000\nsystem_usec 1000\n" +
+			"nr_periods 10\nnr_throttled 2\nthrottled_usec 500\n",
+		"fs/cgroup/system.slice/memory.current":                        "4096\n",
+		"fs/cgroup/system.slice/memory.max":                            "max\n",
+		"fs/cgroup/system.slice/memory.events":                         "low 0\noom 1\n",
+		"fs/cgroup/system.slice/pids.current":                          "12\n",
+		"fs/cgroup/system.slice/pids.max":                              "100\n",
+		"fs/cgroup/system.slice/io.stat":                               "8:0 rbytes=1024 wbytes=2048 rios=1 wios=2 dbytes=0 dios=0\n",
+		"fs/cgroup/system.slice/nginx.service/memory.current":          "1024\n",
+		"fs/cgroup/system.slice/nginx.service/deep.scope/pids.current": "1\n",
+		"fs/cgroup/user.slice/memory.current":                          "2048\n",
+	})
+	defer cleanup()
+	defer setEnv("HOST_SYS", root)()
+
+	if err := os.MkdirAll(filepath.Join(root, "dev", "block"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("../../devices/virtual/block/sda", filepath.Join(root, "dev", "block", "8:0")); err != nil {
+		t.Fatal(err)
+	}
+
+	system := "cgroup=/system.slice,unit=system.slice"
+	nginx := "cgroup=/system.slice/nginx.service,unit=nginx.service"
+	user := "cgroup=/user.slice,unit=user.slice"
+
+	tests := []struct {
+		name  string
+		level uint8
+		paths []string
+		want  map[string]interface{}
+	}{
+		{
+			name:  "level 1",
+			level: 1,
+			want: map[string]interface{}{
+				"os.cgroup.cpu.usage.us{" + system + "}": uint64(3000),
+				"os.cgroup.mem.current{" + system + "}":  uint64(4096),
+				"os.cgroup.mem.current{" + nginx + "}":   uint64(1024),
+				"os.cgroup.mem.current{" + user + "}":    uint64(2048),
+			},
+		},
+		{
+			name:  "level 3 filtered",
+			level: 3,
+			paths: []string{"/system.slice"},
+			want: map[string]interface{}{
+				"os.cgroup.cpu.usage.us{" + system + "}":                                                  uint64(3000),
+				"os.cgroup.cpu.user.us{" + system + "}":                                                   uint64(2000),
+				"os.cgroup.cpu.system.us{" + system + "}":                                                 uint64(1000),
+				"os.cgroup.cpu.periods{" + system + "}":                                                   uint64(10),
+				"os.cgroup.cpu.throttled.periods{" + system + "}":                                         uint64(2),
+				"os.cgroup.cpu.throttled.us{" + system + "}":                                              uint64(500),
+				"os.cgroup.mem.current{" + system + "}":                                                   uint64(4096),
+				"os.cgroup.mem.events{cgroup=/system.slice,event=low,unit=system.slice}":                  uint64(0),
+				"os.cgroup.mem.events{cgroup=/system.slice,event=oom,unit=system.slice}":                  uint64(1),
+				"os.cgroup.pids.current{" + system + "}":                                                  uint64(12),
+				"os.cgroup.pids.max{" + system + "}":                                                      uint64(100),
+				"os.cgroup.io.bytes{cgroup=/system.slice,device=sda,direction=read,unit=system.slice}":    uint64(1024),
+				"os.cgroup.io.bytes{cgroup=/system.slice,device=sda,direction=write,unit=system.slice}":   uint64(2048),
+				"os.cgroup.io.bytes{cgroup=/system.slice,device=sda,direction=discard,unit=system.slice}": uint64(0),
+				"os.cgroup.io.ops{cgroup=/system.slice,device=sda,direction=read,unit=system.slice}":      uint64(1),
+				"os.cgroup.io.ops{cgroup=/system.slice,device=sda,direction=write,unit=system.slice}":     uint64(2),
+				"os.cgroup.io.ops{cgroup=/system.slice,device=sda,direction=discard,unit=system.slice}":   uint64(0),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		c := &Cgroup{
+			root:  filepath.Join(root, "fs", "cgroup"),
+			depth: 2,
+			paths: tt.paths,
+			rate:  core.NewRate(core.CountersOnly),
+			level: tt.level,
+		}
+		if err := c.scrape(); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		checkValues(t, tt.name, c.Samples(), tt.want)
+	}
+}
+
+func TestCgroupLabels(t *testing.T) {
+	tests := map[string]core.Labels{
+		"/system.slice/nginx.service": {"cgroup": "/system.slice/nginx.service", "unit": "nginx.service"},
+		"/user.slice/session-1.scope": {"cgroup": "/user.slice/session-1.scope", "unit": "session-1.scope"},
+		"/docker/0123abcd":            {"cgroup": "/docker/0123abcd"},
+	}
+
+	for name, want := range tests {
+		got := cgroupLabels(name)
+		if len(got) != len(want) || got["cgroup"] != want["cgroup"] || got["unit"] != want["unit"] {
+			t.Errorf("cgroupLabels(%s) = %v, want %v", name, got, want)
+		}
+	}
+}
@@ -2,9 +2,7 @@ package collectors
 
 import (
 	"bufio"
-	"bytes"
 	"encoding/json"
-	"fmt"
 	"io"
 	"os/exec"
 	"regexp"
@@ -20,8 +18,7 @@ import (
 // Collector collects external metrics
 type Collector struct {
 	mutex       sync.RWMutex
-	sensision   bytes.Buffer
-	fetched     []bytes.Buffer
+	fetched     [][]core.Sample // samples of the last runs, most recent first
 	keepMetrics bool
 	path        string
 }
@@ -30,7 +27,7 @@ type Collector struct {
 func NewCollector(path string, period uint, keep uint, keepMetrics bool) *Collector {
 	c := &Collector{
 		path:        path,
-		fetched:     make([]bytes.Buffer, keep),
+		fetched:     make([][]core.Sample, keep),
 		keepMetrics: keepMetrics,
 	}
 
@@ -46,34 +43,43 @@ func NewCollector(path string, period uint, keep uint, keepMetrics bool) *Collec
 	return c
 }
 
-// Metrics delivers metrics.
-func (c *Collector) Metrics() *bytes.Buffer {
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
+// Samples delivers metrics, without altering them, as each consumer (http
+// endpoint, flush files, sinks) gathers them on its own period.
+func (c *Collector) Samples() []core.Sample {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
 
-	var top bytes.Buffer
-	top.Grow(c.sensision.Len())
-	top.Write(c.sensision.Bytes())
-
-	scollectorCompat := true
-	if c.keepMetrics {
-		scollectorCompat = len(top.Bytes()) > 0
+	var res []core.Sample
+	for i := 0; i < len(c.fetched); i++ {
+		res = append(res, c.fetched[i]...)
 	}
+	return res
+}
 
-	if scollectorCompat {
-		for i := len(c.fetched) - 1; i > 0; i-- {
-			c.fetched[i] = c.fetched[i-1]
-		}
-		c.fetched[0] = top
+// add appends a sample of the current run, the first one of a run opening
+// a new slot in the keep window.
+func (c *Collector) add(started bool, s core.Sample) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
 
-		c.sensision.Reset()
+	if len(c.fetched) == 0 {
+		return
 	}
+	if !started {
+		c.shift()
+	}
+	c.fetched[0] = append(c.fetched[0], s)
+}
 
-	var res bytes.Buffer
-	for i := 0; i < len(c.fetched); i++ {
-		res.Write(c.fetched[i].Bytes())
+// shift drops the oldest run of the keep window, the caller holding the lock
+func (c *Collector) shift() {
+	if len(c.fetched) == 0 {
+		return
+	}
+	for i := len(c.fetched) - 1; i > 0; i-- {
+		c.fetched[i] = c.fetched[i-1]
 	}
-	return &res
+	c.fetched[0] = nil
 }
 
 // DataPoint is an opentsdb data point
@@ -126,6 +132,7 @@ func (c *Collector) scrape() (cmdError error) {
 	}()
 
 	// Stdout handler
+	started := false
 	for s.Scan() {
 		t := strings.TrimSpace(s.Text())
 		if len(t) == 0 {
@@ -207,16 +214,14 @@ func (c *Collector) scrape() (cmdError error) {
 		}
 
 		// add metric
-		var labels string
-		for k, v := range dp.Tags {
-			labels += core.ToLabels(k, v) + ","
-		}
-		labels = strings.TrimSuffix(labels, ",")
+		c.add(started, core.NewGauge(dp.Timestamp*1000000, dp.Metric, core.Labels(dp.Tags), dp.Value))
+		started = true
+	}
 
+	// With keepMetrics, runs without output keep the last values available
+	if !started && !c.keepMetrics {
 		c.mutex.Lock()
-
-		gts := core.GetSeriesOutput(dp.Timestamp*1000000, dp.Metric, fmt.Sprintf("{%v}", labels), dp.Value)
-		c.sensision.WriteString(gts)
+		c.shift()
 		c.mutex.Unlock()
 	}
 
@@ -0,0 +1,160 @@
+package collectors
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ovh/noderig/core"
+	log "github.com/sirupsen/logrus"
+)
+
+func init() {
+	core.Describe("os.conntrack.count", core.NoUnit, "Conntrack table entries")
+	core.Describe("os.conntrack.max", core.NoUnit, "Conntrack table size")
+	core.Describe("os.conntrack.fill", core.Percent, "Percentage of the conntrack table used")
+	core.Describe("os.conntrack.insert.failed", core.NoUnit, "Conntrack entries insertion failures")
+	core.Describe("os.conntrack.drop", core.NoUnit, "Packets dropped on conntrack entries creation failures")
+	core.Describe("os.conntrack.early.drop", core.NoUnit, "Conntrack entries dropped to make room on a full table")
+	core.Describe("os.conntrack.stat", core.NoUnit, "Conntrack statistics")
+}
+
+// conntrack statistics exposed under their own class
+var conntrackStats = map[string]string{
+	"insert_failed": "os.conntrack.insert.failed",
+	"drop":          "os.conntrack.drop",
+	"early_drop":    "os.conntrack.early.drop",
+}
+
+// Conntrack collects netfilter connection tracking metrics
+type Conntrack struct {
+	mutex   sync.RWMutex
+	samples []core.Sample
+	rate    *core.Rate
+	level   uint8
+}
+
+// NewConntrack returns an initialized Conntrack collector.
+func NewConntrack(period uint, level uint8, opts interface{}) *Conntrack {
+	c := &Conntrack{
+		level: level,
+		rate:  core.NewRate(counterMode(opts)),
+	}
+
+	if level == 0 {
+		return c
+	}
+
+	tick := time.NewTicker(time.Duration(period) * time.Millisecond)
+	go func() {
+		for range tick.C {
+			if err := c.scrape(); err != nil {
+				log.Error(err)
+			}
+		}
+	}()
+
+	return c
+}
+
+// Samples delivers metrics.
+func (c *Conntrack) Samples() []core.Sample {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	return append([]core.Sample(nil), c.samples...)
+}
+
+func (c *Conntrack) scrape() error {
+	count, _, err := readUint(procPath("sys", "net", "netfilter", "nf_conntrack_count"))
+	if os.IsNotExist(err) {
+		// the nf_conntrack module is not loaded (yet)
+		log.Debug("Conntrack is not available, skip it")
+		c.mutex.Lock()
+		c.samples = nil
+		c.mutex.Unlock()
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	max, _, err := readUint(procPath("sys", "net", "netfilter", "nf_conntrack_max"))
+	if err != nil {
+		return err
+	}
+
+	var stats map[string]uint64
+	var keys []string
+	if c.level > 1 {
+		stats, keys, err = readConntrackStats(procPath("net", "stat", "nf_conntrack"))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	now := time.Now().UnixNano() / 1000
+	class := "os.conntrack"
+
+	samples := []core.Sample{
+		core.NewGauge(now, class+".count", nil, count),
+		core.NewGauge(now, class+".max", nil, max),
+	}
+	if max > 0 {
+		samples = append(samples, core.NewGauge(now, class+".fill", nil, float64(count)/float64(max)*100))
+	}
+
+	for _, key := range keys {
+		if stat, ok := conntrackStats[key]; ok {
+			samples = append(samples, core.NewCounter(now, stat, nil, stats[key]))
+		} else if c.level > 2 && key != "entries" {
+			samples = append(samples, core.NewCounter(now, class+".stat", core.Labels{"stat": key}, stats[key]))
+		}
+	}
+
+	// protect consistency
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.samples = c.rate.Apply(samples)
+
+	return nil
+}
+
+// readConntrackStats sums the per cpu statistics of /proc/net/stat/nf_conntrack,
+// whose values are hexadecimal:
+// entries  clashres found new invalid ignore delete ...
+// 00000000  00000000 00000000 00000000 00000000 00000000 00000000 ...
+func readConntrackStats(path string) (map[string]uint64, []string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	if !scanner.Scan() {
+		return nil, nil, scanner.Err()
+	}
+	keys := strings.Fields(scanner.Text())
+
+	res := make(map[string]uint64)
+	for scanner.Scan() {
+		values := strings.Fields(scanner.Text())
+		for i, v := range values {
+			if i >= len(keys) {
+				break
+			}
+			n, err := strconv.ParseUint(v, 16, 64)
+			if err != nil {
+				continue
+			}
+			res[keys[i]] += n
+		}
+	}
+
+	return res, keys, scanner.Err()
+}
@@ -0,0 +1,94 @@
+package collectors
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ovh/noderig/core"
+)
+
+const conntrackStatFile = "entries  clashres found invalid insert_failed drop early_drop\n" +
+	"0000002a  00000001 00000010 0000000a 00000001 00000002 00000000\n" +
+	"0000002a  00000000 00000006 00000005 00000000 00000001 0000000f ffff\n"
+
+func TestReadConntrackStats(t *testing.T) {
+	root, cleanup := fakeTree(t, map[string]string{
+		"nf_conntrack": conntrackStatFile,
+		"empty":        "",
+		"invalid":      "entries found\nzz 00000010\n",
+	})
+	defer cleanup()
+
+	tests := []struct {
+		file  string
+		stats map[string]uint64
+		keys  []string
+		err   bool
+	}{
+		{
+			file: "nf_conntrack",
+			stats: map[string]uint64{
+				"entries": 84, "clashres": 1, "found": 22, "invalid": 15,
+				"insert_failed": 1, "drop": 3, "early_drop": 15,
+			},
+			keys: []string{"entries", "clashres", "found", "invalid", "insert_failed", "drop", "early_drop"},
+		},
+		{file: "empty"},
+		{file: "invalid", stats: map[string]uint64{"found": 16}, keys: []string{"entries", "found"}},
+		{file: "missing", err: true},
+	}
+
+	for _, tt := range tests {
+		stats, keys, err := readConntrackStats(filepath.Join(root, tt.file))
+		if (err != nil) != tt.err {
+			t.Errorf("%s: error = %v, want error %v", tt.file, err, tt.err)
+			continue
+		}
+		if tt.err {
+			continue
+		}
+		if !reflect.DeepEqual(stats, tt.stats) {
+			t.Errorf("%s: got stats %v, want %v", tt.file, stats, tt.stats)
+		}
+		if !reflect.DeepEqual(keys, tt.keys) {
+			t.Errorf("%s: got keys %v, want %v", tt.file, keys, tt.keys)
+		}
+	}
+}
+
+func TestConntrackScrape(t *testing.T) {
+	root, cleanup := fakeTree(t, map[string]string{
+		"sys/net/netfilter/nf_conntrack_count": "250\n",
+		"sys/net/netfilter/nf_conntrack_max":   "1000\n",
+		"net/stat/nf_conntrack":                conntrackStatFile,
+	})
+	defer cleanup()
+	defer setEnv("HOST_PROC", root)()
+
+	c := &Conntrack{rate: core.NewRate(core.CountersOnly), level: 3}
+	if err := c.scrape(); err != nil {
+		t.Fatal(err)
+	}
+
+	checkValues(t, "level 3", c.Samples(), map[string]interface{}{
+		"os.conntrack.count{}":             uint64(250),
+		"os.conntrack.max{}":               uint64(1000),
+		"os.conntrack.fill{}":              25.0,
+		"os.conntrack.insert.failed{}":     uint64(1),
+		"os.conntrack.drop{}":              uint64(3),
+		"os.conntrack.early.drop{}":        uint64(15),
+		"os.conntrack.stat{stat=clashres}": uint64(1),
+		"os.conntrack.stat{stat=found}":    uint64(22),
+		"os.conntrack.stat{stat=invalid}":  uint64(15),
+	})
+
+	// module unloaded
+	defer setEnv("HOST_PROC", filepath.Join(root, "missing"))()
+	if err := c.scrape(); err != nil {
+		t.Fatal(err)
+	}
+	if samples := c.Samples(); len(samples) != 0 {
+		t.Errorf("got samples %v without conntrack", samples)
+	}
+}
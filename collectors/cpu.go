@@ -1,9 +1,8 @@
 package collectors
 
 import (
-	"bytes"
-	"fmt"
 	"regexp"
+	"strconv"
 	"sync"
 	"time"
 
@@ -13,27 +12,61 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
+func init() {
+	core.Describe("os.cpu", core.Percent, "Combined percentage of cpu usage")
+	core.Describe("os.cpu.iowait", core.Percent, "Percentage of cpu iowait")
+	core.Describe("os.cpu.user", core.Percent, "Percentage of cpu user")
+	core.Describe("os.cpu.systems", core.Percent, "Percentage of cpu systems")
+	core.Describe("os.cpu.nice", core.Percent, "Percentage of cpu nice")
+	core.Describe("os.cpu.irq", core.Percent, "Percentage of cpu irq")
+	core.Describe("os.cpu.steal", core.Percent, "Percentage of cpu stolen")
+	core.Describe("os.cpu.idle", core.Percent, "Percentage of cpu idle")
+	core.Describe("os.cpu.temperature", core.Celsius, "Temperature of cpu (celsius)")
+	core.Describe("os.cpu.context.switches", core.NoUnit, "Context switches")
+	core.Describe("os.cpu.forks", core.NoUnit, "Processes and threads created")
+}
+
 // CPU collects cpu related metrics
 type CPU struct {
 	times []cpu.TimesStat
+	nodes map[string]string // NUMA node of each cpu
+
+	topInterrupts int
+	irqs          map[string]bool   // interrupts reported
+	irqTotals     map[string]uint64 // interrupts counts at the previous scrape
 
-	mutex     sync.RWMutex
-	sensision bytes.Buffer
-	level     uint8
-	modules   []string
+	mutex   sync.RWMutex
+	samples []core.Sample
+	rate    *core.Rate
+	level   uint8
+	modules []string
 }
 
 // NewCPU returns an initialized CPU collector.
-func NewCPU(period uint, level uint8, modules []string) *CPU {
+func NewCPU(period uint, level uint8, modules []string, opts interface{}) *CPU {
+	mode := counterMode(opts)
+	if !hasOption(opts, "counters") {
+		// interrupt counts since boot are of little use without their rates
+		for _, m := range modules {
+			if m == "softirq" || m == "interrupts" {
+				mode = core.CountersAndRates
+			}
+		}
+	}
+
 	c := &CPU{
-		level:   level,
-		modules: modules,
+		topInterrupts: intOption(opts, "top-interrupts", 10),
+		rate:          core.NewRate(mode),
+		level:         level,
+		modules:       modules,
 	}
 
 	if level == 0 {
 		return c
 	}
 
+	c.nodes = cpuNodes()
+
 	tick := time.NewTicker(time.Duration(period) * time.Millisecond)
 	go func() {
 		for range tick.C {
@@ -46,14 +79,12 @@ func NewCPU(period uint, level uint8, modules []string) *CPU {
 	return c
 }
 
-// Metrics delivers metrics.
-func (c *CPU) Metrics() *bytes.Buffer {
+// Samples delivers metrics.
+func (c *CPU) Samples() []core.Sample {
 	c.mutex.RLock()
 	defer c.mutex.RUnlock()
 
-	var res bytes.Buffer
-	res.Write(c.sensision.Bytes())
-	return &res
+	return append([]core.Sample(nil), c.samples...)
 }
 
 // https://github.com/Leo-G/DevopsWiki/wiki/How-Linux-CPU-Usage-Time-and-Percentage-is-calculated
@@ -94,18 +125,24 @@ func (c *CPU) scrape() error {
 
 	c.times = times
 
+	var stat map[string]uint64
+	if c.level > 1 {
+		if stat, err = readFlatKeyed(procPath("stat")); err != nil {
+			return err
+		}
+	}
+
 	// protect consistency
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
 	// Delete previous metrics
-	c.sensision.Reset()
+	c.samples = nil
 
 	class := "os.cpu"
 
 	now := time.Now().UnixNano() / 1000
-	gts := core.GetSeriesOutput(now, class, "{}", global)
-	c.sensision.WriteString(gts)
+	c.samples = append(c.samples, core.NewGauge(now, class, nil, global))
 
 	if c.level == 2 {
 		iowait := 0.0
@@ -113,100 +150,94 @@ func (c *CPU) scrape() error {
 			iowait += v
 		}
 		iowait = iowait / float64(len(iowaits)) * 100
-		gts := core.GetSeriesOutput(now, class+".iowait", "{}", iowait)
-		c.sensision.WriteString(gts)
+		c.samples = append(c.samples, core.NewGauge(now, class+".iowait", nil, iowait))
 
 		user := 0.0
 		for _, v := range users {
 			user += v
 		}
 		user = user / float64(len(users)) * 100
-		gts = core.GetSeriesOutput(now, class+".user", "{}", user)
-		c.sensision.WriteString(gts)
+		c.samples = append(c.samples, core.NewGauge(now, class+".user", nil, user))
 
 		system := 0.0
 		for _, v := range systems {
 			system += v
 		}
 		system = system / float64(len(systems)) * 100
-		gts = core.GetSeriesOutput(now, class+".systems", "{}", system)
-		c.sensision.WriteString(gts)
+		c.samples = append(c.samples, core.NewGauge(now, class+".systems", nil, system))
 
 		nice := 0.0
 		for _, v := range nices {
 			nice += v
 		}
 		nice = nice / float64(len(nices)) * 100
-		gts = core.GetSeriesOutput(now, class+".nice", "{}", nice)
-		c.sensision.WriteString(gts)
+		c.samples = append(c.samples, core.NewGauge(now, class+".nice", nil, nice))
 
 		irq := 0.0
 		for _, v := range irqs {
 			irq += v
 		}
 		irq = irq / float64(len(irqs)) * 100
-		gts = core.GetSeriesOutput(now, class+".irq", "{}", irq)
-		c.sensision.WriteString(gts)
+		c.samples = append(c.samples, core.NewGauge(now, class+".irq", nil, irq))
 
 		steal := 0.0
 		for _, v := range steals {
 			steal += v
 		}
 		steal = steal / float64(len(steals)) * 100
-		gts = core.GetSeriesOutput(now, class+".steal", "{}", steal)
-		c.sensision.WriteString(gts)
+		c.samples = append(c.samples, core.NewGauge(now, class+".steal", nil, steal))
 
 		idle := 0.0
 		for _, v := range idles {
 			idle += v
 		}
 		idle = idle / float64(len(idles)) * 100
-		gts = core.GetSeriesOutput(now, class+".idle", "{}", idle)
-		c.sensision.WriteString(gts)
+		c.samples = append(c.samples, core.NewGauge(now, class+".idle", nil, idle))
+	}
+
+	if c.level > 1 {
+		if v, ok := stat["ctxt"]; ok {
+			c.samples = append(c.samples, core.NewCounter(now, class+".context.switches", nil, v))
+		}
+		if v, ok := stat["processes"]; ok {
+			c.samples = append(c.samples, core.NewCounter(now, class+".forks", nil, v))
+		}
 	}
 
 	if c.level == 3 {
 		for i, v := range iowaits {
-			gts := core.GetSeriesOutput(now,
-				fmt.Sprintf("%v.iowait", class),
-				fmt.Sprintf("{%v}", core.ToLabels("chore", i)), v*100)
-			c.sensision.WriteString(gts)
+			c.samples = append(c.samples, core.NewGauge(now, class+".iowait",
+				c.coreLabels(i, times[i].CPU), v*100))
 		}
 
 		for i, v := range users {
-			gts := core.GetSeriesOutput(now, fmt.Sprintf("%v.user", class),
-				fmt.Sprintf("{%v}", core.ToLabels("chore", i)), v*100)
-			c.sensision.WriteString(gts)
+			c.samples = append(c.samples, core.NewGauge(now, class+".user",
+				c.coreLabels(i, times[i].CPU), v*100))
 		}
 
 		for i, v := range systems {
-			gts := core.GetSeriesOutput(now, fmt.Sprintf("%v.systems", class),
-				fmt.Sprintf("{%v}", core.ToLabels("chore", i)), v*100)
-			c.sensision.WriteString(gts)
+			c.samples = append(c.samples, core.NewGauge(now, class+".systems",
+				c.coreLabels(i, times[i].CPU), v*100))
 		}
 
 		for i, v := range nices {
-			gts := core.GetSeriesOutput(now, fmt.Sprintf("%v.nice", class),
-				fmt.Sprintf("{%v}", core.ToLabels("chore", i)), v*100)
-			c.sensision.WriteString(gts)
+			c.samples = append(c.samples, core.NewGauge(now, class+".nice",
+				c.coreLabels(i, times[i].CPU), v*100))
 		}
 
 		for i, v := range irqs {
-			gts := core.GetSeriesOutput(now, fmt.Sprintf("%v.irq", class),
-				fmt.Sprintf("{%v}", core.ToLabels("chore", i)), v*100)
-			c.sensision.WriteString(gts)
+			c.samples = append(c.samples, core.NewGauge(now, class+".irq",
+				c.coreLabels(i, times[i].CPU), v*100))
 		}
 
 		for i, v := range steals {
-			gts := core.GetSeriesOutput(now, fmt.Sprintf("%v.steal", class),
-				fmt.Sprintf("{%v}", core.ToLabels("chore", i)), v*100)
-			c.sensision.WriteString(gts)
+			c.samples = append(c.samples, core.NewGauge(now, class+".steal",
+				c.coreLabels(i, times[i].CPU), v*100))
 		}
 
 		for i, v := range idles {
-			gts := core.GetSeriesOutput(now, fmt.Sprintf("%v.idle", class),
-				fmt.Sprintf("{%v}", core.ToLabels("chore", i)), v*100)
-			c.sensision.WriteString(gts)
+			c.samples = append(c.samples, core.NewGauge(now, class+".idle",
+				c.coreLabels(i, times[i].CPU), v*100))
 		}
 	}
 
@@ -215,12 +246,14 @@ func (c *CPU) scrape() error {
 		case "temperature":
 			temps, err := host.SensorsTemperatures()
 			if err != nil {
-				return err
+				log.WithError(err).Warnf("[CPU] module '%s' failed", m)
+				continue
 			}
 
 			platform, _, _, err := host.PlatformInformation()
 			if err != nil {
-				return err
+				log.WithError(err).Warnf("[CPU] module '%s' failed", m)
+				continue
 			}
 
 			// Get CPU temperature
@@ -233,9 +266,8 @@ func (c *CPU) scrape() error {
 				submatches := re.FindStringSubmatch(temp.SensorKey)
 				if len(submatches) > 0 {
 
-					gts := core.GetSeriesOutput(now, fmt.Sprintf("%v.temperature", class),
-						fmt.Sprintf("{%v}", core.ToLabels("id", submatches[1])), temp.Temperature)
-					c.sensision.WriteString(gts)
+					c.samples = append(c.samples, core.NewGauge(now, class+".temperature",
+						core.Labels{"id": submatches[1]}, temp.Temperature))
 				}
 			}
 
@@ -249,16 +281,43 @@ func (c *CPU) scrape() error {
 				for _, temp := range temps {
 					submatches := re.FindStringSubmatch(temp.SensorKey)
 					if len(submatches) > 0 {
-						gts := core.GetSeriesOutput(now, fmt.Sprintf("%v.temperature", class),
-							fmt.Sprintf("{%v}", core.ToLabels("core", submatches[1])), temp.Temperature)
-						c.sensision.WriteString(gts)
+						c.samples = append(c.samples, core.NewGauge(now, class+".temperature",
+							core.Labels{"core": submatches[1]}, temp.Temperature))
 					}
 				}
 			}
+		case "frequency":
+			c.samples = append(c.samples, c.frequency(now)...)
+		case "softirq":
+			samples, err := c.softirqs(now)
+			if err != nil {
+				log.WithError(err).Warnf("[CPU] module '%s' failed", m)
+				continue
+			}
+			c.samples = append(c.samples, samples...)
+		case "interrupts":
+			samples, err := c.interrupts(now)
+			if err != nil {
+				log.WithError(err).Warnf("[CPU] module '%s' failed", m)
+				continue
+			}
+			c.samples = append(c.samples, samples...)
 		default:
 			log.Warnf("[CPU] module '%s' not found", m)
 		}
 	}
 
+	c.samples = c.rate.Apply(c.samples)
+
 	return nil
 }
+
+// coreLabels labels a per core series by its index, and by its NUMA node
+// when known.
+func (c *CPU) coreLabels(i int, name string) core.Labels {
+	labels := core.Labels{"chore": strconv.Itoa(i)}
+	if node, ok := c.nodes[name]; ok {
+		labels["node"] = node
+	}
+	return labels
+}
@@ -0,0 +1,299 @@
+package collectors
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ovh/noderig/core"
+)
+
+func init() {
+	core.Describe("os.cpu.frequency", core.Hertz, "Current scaling frequency of cpu (hertz)")
+	core.Describe("os.cpu.throttle", core.NoUnit, "Thermal throttling events of cpu core")
+	core.Describe("os.cpu.throttle.package", core.NoUnit, "Thermal throttling events of cpu package")
+	core.Describe("os.cpu.softirq", core.NoUnit, "Software interrupts handled")
+	core.Describe("os.cpu.interrupts", core.NoUnit, "Hardware interrupts handled")
+}
+
+// frequency reads the current scaling frequency and the thermal throttling
+// counters of the cpus, skipping the ones without cpufreq or thermal
+// throttling support, as virtual machines.
+func (c *CPU) frequency(now int64) []core.Sample {
+	dirs, _ := filepath.Glob(sysPath("devices", "system", "cpu", "cpu[0-9]*"))
+
+	type cpuValue struct {
+		id    int
+		value uint64
+	}
+	var freqs []cpuValue
+	var throttles []cpuValue
+	packages := make(map[string]uint64)
+
+	for _, dir := range dirs {
+		id, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(dir), "cpu"))
+		if err != nil {
+			continue
+		}
+
+		if v, ok, _ := readUint(filepath.Join(dir, "cpufreq", "scaling_cur_freq")); ok {
+			freqs = append(freqs, cpuValue{id, v * 1000}) // kHz
+		}
+		if v, ok, _ := readUint(filepath.Join(dir, "thermal_throttle", "core_throttle_count")); ok {
+			throttles = append(throttles, cpuValue{id, v})
+		}
+
+		// package counters are repeated on each of its cpus
+		pkg, err := ioutil.ReadFile(filepath.Join(dir, "topology", "physical_package_id"))
+		if err != nil {
+			continue
+		}
+		if v, ok, _ := readUint(filepath.Join(dir, "thermal_throttle", "package_throttle_count")); ok {
+			packages[strings.TrimSpace(string(pkg))] = v
+		}
+	}
+
+	sort.Slice(freqs, func(i, j int) bool { return freqs[i].id < freqs[j].id })
+	sort.Slice(throttles, func(i, j int) bool { return throttles[i].id < throttles[j].id })
+
+	class := "os.cpu"
+	var samples []core.Sample
+
+	if len(freqs) > 0 {
+		total := 0.0
+		for _, f := range freqs {
+			total += float64(f.value)
+		}
+		samples = append(samples, core.NewGauge(now, class+".frequency", nil, total/float64(len(freqs))))
+	}
+
+	ids := make([]string, 0, len(packages))
+	for id := range packages {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		samples = append(samples, core.NewCounter(now, class+".throttle.package", core.Labels{"id": id}, packages[id]))
+	}
+
+	if c.level >= 2 {
+		for _, f := range freqs {
+			samples = append(samples, core.NewGauge(now, class+".frequency",
+				c.coreLabels(f.id, "cpu"+strconv.Itoa(f.id)), f.value))
+		}
+		for _, t := range throttles {
+			samples = append(samples, core.NewCounter(now, class+".throttle",
+				c.coreLabels(t.id, "cpu"+strconv.Itoa(t.id)), t.value))
+		}
+	}
+
+	return samples
+}
+
+// softirqs reads /proc/softirqs, per type counts being summed over the cpus
+// below level 3.
+func (c *CPU) softirqs(now int64) ([]core.Sample, error) {
+	cpus, rows, err := readPerCPUTable(procPath("softirqs"))
+	if err != nil {
+		return nil, err
+	}
+
+	var samples []core.Sample
+	for _, row := range rows {
+		typ := strings.ToLower(row.name)
+		samples = append(samples, c.perCPUSamples(now, "os.cpu.softirq", core.Labels{"type": typ}, cpus, row.counts)...)
+	}
+	return samples, nil
+}
+
+// interrupts reads /proc/interrupts, keeping the interrupts the most
+// handled since the previous scrape. The selection is sticky so that the
+// reported counters stay continuous, only the slots of vanished interrupts
+// being filled again. Nothing is reported on the first scrape, which only
+// records the counts.
+func (c *CPU) interrupts(now int64) ([]core.Sample, error) {
+	cpus, rows, err := readPerCPUTable(procPath("interrupts"))
+	if err != nil {
+		return nil, err
+	}
+
+	totals := make(map[string]uint64, len(rows))
+	for _, row := range rows {
+		for _, v := range row.counts {
+			totals[row.name] += v
+		}
+	}
+
+	previous := c.irqTotals
+	c.irqTotals = totals
+	if previous == nil {
+		return nil, nil
+	}
+
+	selected := make(map[string]bool, len(c.irqs))
+	for name := range c.irqs {
+		if _, ok := totals[name]; ok {
+			selected[name] = true
+		}
+	}
+
+	var candidates []string
+	for _, row := range rows {
+		if !selected[row.name] {
+			candidates = append(candidates, row.name)
+		}
+	}
+
+	delta := func(name string) uint64 {
+		if totals[name] < previous[name] {
+			return totals[name] // reset
+		}
+		return totals[name] - previous[name]
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		di, dj := delta(candidates[i]), delta(candidates[j])
+		if di != dj {
+			return di > dj
+		}
+		return totals[candidates[i]] > totals[candidates[j]]
+	})
+	if free := c.topInterrupts - len(selected); c.topInterrupts > 0 && len(candidates) > free {
+		candidates = candidates[:free]
+	}
+	for _, name := range candidates {
+		selected[name] = true
+	}
+	c.irqs = selected
+
+	var samples []core.Sample
+	for _, row := range rows { // keep the file order
+		if !selected[row.name] {
+			continue
+		}
+
+		labels := core.Labels{"irq": row.name}
+		if row.device != "" {
+			labels["device"] = row.device
+		}
+		samples = append(samples, c.perCPUSamples(now, "os.cpu.interrupts", labels, cpus, row.counts)...)
+	}
+	return samples, nil
+}
+
+// perCPUSamples returns a counter per cpu at level 3, their sum otherwise
+func (c *CPU) perCPUSamples(now int64, class string, labels core.Labels, cpus []string, counts []uint64) []core.Sample {
+	if c.level < 3 {
+		var total uint64
+		for _, v := range counts {
+			total += v
+		}
+		return []core.Sample{core.NewCounter(now, class, labels, total)}
+	}
+
+	samples := make([]core.Sample, 0, len(counts))
+	for i, v := range counts {
+		name := strings.ToLower(cpus[i])
+		id, err := strconv.Atoi(strings.TrimPrefix(name, "cpu"))
+		if err != nil {
+			continue
+		}
+
+		cpuLabels := c.coreLabels(id, name)
+		for k, l := range labels {
+			cpuLabels[k] = l
+		}
+		samples = append(samples, core.NewCounter(now, class, cpuLabels, v))
+	}
+	return samples
+}
+
+// perCPURow is a line of a per cpu counters table
+type perCPURow struct {
+	name   string
+	counts []uint64
+	device string
+}
+
+// readPerCPUTable reads a /proc/interrupts like file, made of a cpus header
+// and a line of counts per cpu for each source:
+// CPU0 CPU1
+// 24: 1 0 IO-APIC 5-edge ACPI:Ged
+// NMI: 0 0 Non-maskable interrupts
+// Sources without a count per cpu, as ERR or MIS on multi cpu hosts, are
+// ignored.
+func readPerCPUTable(path string) ([]string, []perCPURow, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	if !scanner.Scan() {
+		return nil, nil, scanner.Err()
+	}
+	cpus := strings.Fields(scanner.Text())
+
+	var rows []perCPURow
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < len(cpus)+1 || !strings.HasSuffix(fields[0], ":") {
+			continue
+		}
+
+		row := perCPURow{
+			name:   strings.TrimSuffix(fields[0], ":"),
+			counts: make([]uint64, len(cpus)),
+		}
+		valid := true
+		for i := range cpus {
+			v, err := strconv.ParseUint(fields[i+1], 10, 64)
+			if err != nil {
+				valid = false
+				break
+			}
+			row.counts[i] = v
+		}
+		if !valid {
+			continue
+		}
+
+		if _, err := strconv.Atoi(row.name); err == nil {
+			row.device = interruptDevice(fields[len(cpus)+1:])
+		}
+		rows = append(rows, row)
+	}
+
+	return cpus, rows, scanner.Err()
+}
+
+var (
+	interruptHwirq   = regexp.MustCompile(`^[0-9]+(-[a-z]+)?$`)
+	interruptTrigger = map[string]bool{"Edge": true, "Level": true}
+)
+
+// interruptDevice returns the devices of a numbered interrupt, described
+// after its counts by its chip, optionally followed by its hardware irq and
+// trigger, depending on the kernel and the architecture:
+// IO-APIC 2-edge timer
+// IO-APIC-edge timer
+// GICv3 27 Level arch_timer
+func interruptDevice(fields []string) string {
+	if len(fields) == 0 {
+		return ""
+	}
+	fields = fields[1:] // chip
+
+	if len(fields) > 0 && interruptHwirq.MatchString(fields[0]) {
+		fields = fields[1:]
+	}
+	if len(fields) > 0 && interruptTrigger[fields[0]] {
+		fields = fields[1:]
+	}
+	return strings.Join(fields, " ")
+}
@@ -0,0 +1,261 @@
+package collectors
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ovh/noderig/core"
+)
+
+const (
+	softirqsFile = "                    CPU0       CPU1\n" +
+		"          HI:          1          0\n" +
+		"       TIMER:     123456      98765\n" +
+		"      NET_RX:         10         20\n"
+
+	interruptsFile = "           CPU0       CPU1\n" +
+		"  0:         22          0   IO-APIC   2-edge      timer\n" +
+		" 24:       5000       1000  PCI-MSI 65536-edge      nvme0q0, nvme0q1\n" +
+		" 25:          3          4  PCI-MSI 1048576-edge\n" +
+		"NMI:          7          8   Non-maskable interrupts\n" +
+		"ERR:          0\n" +
+		"MIS:          0\n"
+
+	// kernels before 4.12 merge the trigger into the chip name
+	oldInterruptsFile = "           CPU0       CPU1\n" +
+		"  0:         35          0   IO-APIC-edge      timer\n" +
+		"  9:          0          0   IO-APIC-fasteoi   acpi\n" +
+		" 40:        120         80   PCI-MSI-edge      eth0-TxRx-0\n" +
+		" 41:          1          0   PCI-MSI-edge\n"
+
+	armInterruptsFile = "           CPU0       CPU1\n" +
+		" 11:       9870       8765     GICv3  27 Level     arch_timer\n" +
+		" 14:          0          0     GICv3  37 Level     ttyAMA0\n" +
+		" 50:         12         34   ITS-MSI 524288 Edge      nvme0q0, nvme0q1\n" +
+		" 60:          1          0     GICv3  33 Level\n" +
+		"IPI0:       100        200       Rescheduling interrupts\n"
+)
+
+func TestReadPerCPUTable(t *testing.T) {
+	root, cleanup := fakeTree(t, map[string]string{
+		"softirqs":   softirqsFile,
+		"interrupts": interruptsFile,
+		"old":        oldInterruptsFile,
+		"arm":        armInterruptsFile,
+		"empty":      "",
+		"invalid":    "CPU0 CPU1\nHI: 1 x\nTIMER: 2 3\n",
+	})
+	defer cleanup()
+
+	tests := []struct {
+		file string
+		cpus []string
+		rows []perCPURow
+		err  bool
+	}{
+		{
+			file: "softirqs",
+			cpus: []string{"CPU0", "CPU1"},
+			rows: []perCPURow{
+				{name: "HI", counts: []uint64{1, 0}},
+				{name: "TIMER", counts: []uint64{123456, 98765}},
+				{name: "NET_RX", counts: []uint64{10, 20}},
+			},
+		},
+		{
+			file: "interrupts",
+			cpus: []string{"CPU0", "CPU1"},
+			rows: []perCPURow{
+				{name: "0", counts: []uint64{22, 0}, device: "timer"},
+				{name: "24", counts: []uint64{5000, 1000}, device: "nvme0q0, nvme0q1"},
+				{name: "25", counts: []uint64{3, 4}},
+				{name: "NMI", counts: []uint64{7, 8}},
+			},
+		},
+		{
+			file: "old",
+			cpus: []string{"CPU0", "CPU1"},
+			rows: []perCPURow{
+				{name: "0", counts: []uint64{35, 0}, device: "timer"},
+				{name: "9", counts: []uint64{0, 0}, device: "acpi"},
+				{name: "40", counts: []uint64{120, 80}, device: "eth0-TxRx-0"},
+				{name: "41", counts: []uint64{1, 0}},
+			},
+		},
+		{
+			file: "arm",
+			cpus: []string{"CPU0", "CPU1"},
+			rows: []perCPURow{
+				{name: "11", counts: []uint64{9870, 8765}, device: "arch_timer"},
+				{name: "14", counts: []uint64{0, 0}, device: "ttyAMA0"},
+				{name: "50", counts: []uint64{12, 34}, device: "nvme0q0, nvme0q1"},
+				{name: "60", counts: []uint64{1, 0}},
+				{name: "IPI0", counts: []uint64{100, 200}},
+			},
+		},
+		{file: "empty"},
+		{
+			file: "invalid",
+			cpus: []string{"CPU0", "CPU1"},
+			rows: []perCPURow{{name: "TIMER", counts: []uint64{2, 3}}},
+		},
+		{file: "missing", err: true},
+	}
+
+	for _, tt := range tests {
+		cpus, rows, err := readPerCPUTable(filepath.Join(root, tt.file))
+		if (err != nil) != tt.err {
+			t.Errorf("%s: error = %v, want error %v", tt.file, err, tt.err)
+			continue
+		}
+		if !reflect.DeepEqual(cpus, tt.cpus) {
+			t.Errorf("%s: got cpus %v, want %v", tt.file, cpus, tt.cpus)
+		}
+		if !reflect.DeepEqual(rows, tt.rows) {
+			t.Errorf("%s: got rows %+v, want %+v", tt.file, rows, tt.rows)
+		}
+	}
+}
+
+func TestCPUInterruptModules(t *testing.T) {
+	root, cleanup := fakeTree(t, map[string]string{
+		"softirqs":   softirqsFile,
+		"interrupts": interruptsFile,
+	})
+	defer cleanup()
+	defer setEnv("HOST_PROC", root)()
+
+	c := &CPU{level: 1, topInterrupts: 2}
+
+	softirqs, err := c.softirqs(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkValues(t, "softirq", softirqs, map[string]interface{}{
+		"os.cpu.softirq{type=hi}":     uint64(1),
+		"os.cpu.softirq{type=timer}":  uint64(222221),
+		"os.cpu.softirq{type=net_rx}": uint64(30),
+	})
+
+	// the first scrape only records the counts, the interrupts the most
+	// handled since are then kept
+	scrapes := []struct {
+		file string
+		want map[string]interface{}
+	}{
+		{interruptsFile, map[string]interface{}{}},
+		{
+			strings.NewReplacer("5000", "5010", "     3", "  1003").Replace(interruptsFile),
+			map[string]interface{}{
+				"os.cpu.interrupts{device=nvme0q0, nvme0q1,irq=24}": uint64(6010),
+				"os.cpu.interrupts{irq=25}":                         uint64(1007),
+			},
+		},
+		{
+			strings.NewReplacer("5000", "5010", "     3", "  1003", "    22", "900022").Replace(interruptsFile),
+			map[string]interface{}{
+				"os.cpu.interrupts{device=nvme0q0, nvme0q1,irq=24}": uint64(6010),
+				"os.cpu.interrupts{irq=25}":                         uint64(1007),
+			},
+		},
+		{
+			// irq 25 vanished, its slot is given to the most handled one
+			strings.NewReplacer("5000", "5010", " 25:          3          4  PCI-MSI 1048576-edge\n", "", "    22", "900122").Replace(interruptsFile),
+			map[string]interface{}{
+				"os.cpu.interrupts{device=timer,irq=0}":             uint64(900122),
+				"os.cpu.interrupts{device=nvme0q0, nvme0q1,irq=24}": uint64(6010),
+			},
+		},
+	}
+	for i, sc := range scrapes {
+		if err := ioutil.WriteFile(filepath.Join(root, "interrupts"), []byte(sc.file), 0644); err != nil {
+			t.Fatal(err)
+		}
+		interrupts, err := c.interrupts(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkValues(t, fmt.Sprintf("interrupts scrape %d", i), interrupts, sc.want)
+	}
+
+	c = &CPU{level: 3, nodes: map[string]string{"cpu0": "0", "cpu1": "1"}}
+	softirqs, err = c.softirqs(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkValues(t, "softirq per cpu", softirqs, map[string]interface{}{
+		"os.cpu.softirq{chore=0,node=0,type=hi}":     uint64(1),
+		"os.cpu.softirq{chore=1,node=1,type=hi}":     uint64(0),
+		"os.cpu.softirq{chore=0,node=0,type=timer}":  uint64(123456),
+		"os.cpu.softirq{chore=1,node=1,type=timer}":  uint64(98765),
+		"os.cpu.softirq{chore=0,node=0,type=net_rx}": uint64(10),
+		"os.cpu.softirq{chore=1,node=1,type=net_rx}": uint64(20),
+	})
+}
+
+func TestCPUModuleErrors(t *testing.T) {
+	root, cleanup := fakeTree(t, map[string]string{
+		"stat":       "cpu  200 0 100 700 0 0 0 0 0 0\ncpu0 200 0 100 700 0 0 0 0 0 0\n",
+		"interrupts": interruptsFile,
+	})
+	defer cleanup()
+	defer setEnv("HOST_PROC", root)()
+
+	// no softirqs file, the other modules and the rates are still computed
+	c := &CPU{level: 1, topInterrupts: 1, modules: []string{"softirq", "interrupts"}, rate: core.NewRate(core.RatesOnly)}
+	// the first scrape only records the cpu times, the second the interrupts
+	// counts, the third reports them and the fourth their rates
+	for i := 0; i < 4; i++ {
+		if err := c.scrape(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	samples := c.Samples()
+	values := sampleValues(samples)
+	if _, ok := values["os.cpu{}"]; !ok {
+		t.Errorf("no cpu usage in %v", values)
+	}
+	if _, ok := values["os.cpu.interrupts.rate{device=nvme0q0, nvme0q1,irq=24}"]; !ok {
+		t.Errorf("no interrupts rate in %v", values)
+	}
+	for _, s := range samples {
+		if s.Kind == core.Counter {
+			t.Errorf("counter %s exposed in rates only mode", s.Class)
+		}
+	}
+}
+
+func TestNewCPUCounterMode(t *testing.T) {
+	counter := core.NewCounter(1000000, "os.cpu.forks", nil, uint64(10))
+	next := core.NewCounter(2000000, "os.cpu.forks", nil, uint64(20))
+
+	tests := []struct {
+		modules []string
+		opts    interface{}
+		rates   bool
+	}{
+		{nil, nil, false},
+		{[]string{"frequency"}, nil, false},
+		{[]string{"softirq"}, nil, true},
+		{[]string{"interrupts"}, map[string]interface{}{"top-interrupts": 5}, true},
+		{[]string{"interrupts"}, map[string]interface{}{"counters": "counter"}, false},
+	}
+
+	for _, tt := range tests {
+		c := NewCPU(1000, 0, tt.modules, tt.opts)
+		c.rate.Apply([]core.Sample{counter})
+
+		rates := false
+		for _, s := range c.rate.Apply([]core.Sample{next}) {
+			rates = rates || s.Class == "os.cpu.forks"+core.RateSuffix
+		}
+		if rates != tt.rates {
+			t.Errorf("modules %v, options %v: rates %v, want %v", tt.modules, tt.opts, rates, tt.rates)
+		}
+	}
+}
@@ -1,9 +1,8 @@
 package collectors
 
 import (
-	"bytes"
-	"fmt"
 	"path"
+	"strings"
 	"sync"
 	"time"
 
@@ -12,13 +11,48 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
+func init() {
+	core.Describe("os.disk.fs", core.Percent, "Disk used percent")
+	core.Describe("os.disk.fs.readonly", core.NoUnit, "Filesystem mounted read-only")
+	core.Describe("os.disk.fs.error", core.NoUnit, "Filesystem usage cannot be read")
+	core.Describe("os.disk.fs.used", core.Bytes, "Disk used capacity (bytes)")
+	core.Describe("os.disk.fs.total", core.Bytes, "Disk total capacity (bytes)")
+	core.Describe("os.disk.fs.inodes.used", core.NoUnit, "Disk used inodes")
+	core.Describe("os.disk.fs.inodes.total", core.NoUnit, "Disk total inodes")
+	core.Describe("os.disk.fs.bytes.read", core.Bytes, "Disk read count (bytes)")
+	core.Describe("os.disk.fs.bytes.write", core.Bytes, "Disk write count (bytes)")
+	core.Describe("os.disk.fs.io.read", core.NoUnit, "Disk io read count")
+	core.Describe("os.disk.fs.io.write", core.NoUnit, "Disk io write count")
+	core.Describe("os.disk.fs.io.read.ms", core.Milliseconds, "Disk io read time (ms)")
+	core.Describe("os.disk.fs.io.write.ms", core.Milliseconds, "Disk io write time (ms)")
+	core.Describe("os.disk.fs.io", core.NoUnit, "Disk io in progress (count)")
+	core.Describe("os.disk.fs.io.ms", core.Milliseconds, "Disk io time (ms)")
+	core.Describe("os.disk.fs.io.weighted.ms", core.Milliseconds, "Disk io weighted time (ms)")
+}
+
 // Disk collects disk related metrics
 type Disk struct {
 	mutex        sync.RWMutex
-	sensision    bytes.Buffer
+	samples      []core.Sample
+	rate         *core.Rate
 	level        uint8
 	period       uint
 	allowedDisks []string
+
+	mountpoints        []string
+	excludeMountpoints []string
+	fstypes            []string
+	excludeFstypes     []string
+
+	stats *statPool
+}
+
+// filesystem types excluded by default: in-memory, image and pseudo filesystems
+var defaultExcludedFstypes = []string{
+	"tmpfs", "devtmpfs", "ramfs", "overlay", "squashfs",
+	"proc", "sysfs", "cgroup", "cgroup2", "devpts", "mqueue", "debugfs", "tracefs",
+	"securityfs", "pstore", "bpf", "configfs", "fusectl", "hugetlbfs", "autofs",
+	"binfmt_misc", "rpc_pipefs", "nsfs", "efivarfs", "selinuxfs", "fuse.lxcfs",
 }
 
 // NewDisk returns an initialized Disk collector.
@@ -45,9 +79,22 @@ func NewDisk(period uint, level uint8, opts interface{}) *Disk {
 		level:        level,
 		period:       period,
 		allowedDisks: allowedDisks,
+		rate:         core.NewRate(counterMode(opts)),
+
+		mountpoints:        stringsOption(opts, "mountpoints"),
+		excludeMountpoints: stringsOption(opts, "exclude-mountpoints"),
+		fstypes:            stringsOption(opts, "fstypes"),
+		excludeFstypes:     defaultExcludedFstypes,
+	}
+	if options, ok := opts.(map[string]interface{}); ok && options["exclude-fstypes"] != nil {
+		c.excludeFstypes = stringsOption(opts, "exclude-fstypes")
 	}
 
 	if level > 0 {
+		c.stats = newStatPool(
+			intOption(opts, "stat-workers", 4),
+			time.Duration(intOption(opts, "stat-timeout", 2000))*time.Millisecond)
+
 		tick := time.NewTicker(time.Duration(period) * time.Millisecond)
 		go func() {
 			for range tick.C {
@@ -61,14 +108,12 @@ func NewDisk(period uint, level uint8, opts interface{}) *Disk {
 	return c
 }
 
-// Metrics delivers metrics.
-func (c *Disk) Metrics() *bytes.Buffer {
+// Samples delivers metrics.
+func (c *Disk) Samples() []core.Sample {
 	c.mutex.RLock()
 	defer c.mutex.RUnlock()
 
-	var res bytes.Buffer
-	res.Write(c.sensision.Bytes())
-	return &res
+	return append([]core.Sample(nil), c.samples...)
 }
 
 func (c *Disk) scrape() error {
@@ -77,71 +122,66 @@ func (c *Disk) scrape() error {
 		return err
 	}
 
-	parts, err := disk.Partitions(false)
+	// all mounts, filtering being left to the fstypes options
+	parts, err := disk.Partitions(true)
 	if err != nil {
 		return err
 	}
 
-	dev := make(map[string]disk.UsageStat)
+	// one entry per mount point, the last mounted hiding the others
+	var mounts []mount
+	index := make(map[string]int)
 	for _, p := range parts {
-		if _, ok := dev[p.Device]; ok {
+		if !c.selected(p) {
 			continue
 		}
-		usage, err := disk.Usage(p.Mountpoint)
-		if err != nil {
+
+		if i, ok := index[p.Mountpoint]; ok {
+			mounts[i] = mount{PartitionStat: p}
 			continue
 		}
-		dev[p.Device] = *usage
+		index[p.Mountpoint] = len(mounts)
+		mounts = append(mounts, mount{PartitionStat: p})
 	}
 
+	var wg sync.WaitGroup
+	for i := range mounts {
+		wg.Add(1)
+		go func(m *mount) {
+			defer wg.Done()
+			m.usage, m.err = c.stats.usage(m.Mountpoint)
+			if m.err != nil {
+				log.WithError(m.err).Debug("Cannot read filesystem usage")
+			}
+		}(&mounts[i])
+	}
+	wg.Wait()
+
 	// protect consistency
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
-	c.sensision.Reset()
+	c.samples = nil
 
 	now := time.Now().UnixNano() / 1000
 	class := "os.disk.fs"
 
-	for diskPath, usage := range dev {
-		if len(c.allowedDisks) > 0 {
-			_, diskName := path.Split(diskPath) // return "sda1" from "/dev/sda1"
-			if !stringInSlice(diskName, c.allowedDisks) {
-				log.Debug("Disk " + diskPath + " is blacklisted, skip it")
-				continue
-			}
-		}
-
-		gts := core.GetSeriesOutputAttributes(now, class,
-			fmt.Sprintf("{%v}", core.ToLabels("disk", diskPath)), fmt.Sprintf("{mount=%v}", usage.Path), usage.UsedPercent)
-		c.sensision.WriteString(gts)
-	}
+	for _, m := range mounts {
+		c.samples = append(c.samples,
+			fsSample(now, class+".readonly", m, m.readonly()),
+			fsSample(now, class+".error", m, m.err != nil))
 
-	if c.level > 1 {
-		for diskPath, usage := range dev {
-			if len(c.allowedDisks) > 0 {
-				_, diskName := path.Split(diskPath) // return "sda1" from "/dev/sda1"
-				if !stringInSlice(diskName, c.allowedDisks) {
-					log.Debug("Disk " + diskPath + " is blacklisted, skip it")
-					continue
-				}
-			}
+		if m.err != nil {
+			continue
+		}
+		c.samples = append(c.samples, fsSample(now, class, m, m.usage.UsedPercent))
 
-			gts := core.GetSeriesOutputAttributes(now, class+".used",
-				fmt.Sprintf("{%v}", core.ToLabels("disk", diskPath)), fmt.Sprintf("{mount=%v}", usage.Path), usage.Used)
-			c.sensision.WriteString(gts)
-			gts = core.GetSeriesOutputAttributes(now, class+".total",
-				fmt.Sprintf("{%v}", core.ToLabels("disk", diskPath)),
-				fmt.Sprintf("{mount=%v}", usage.Path), usage.Total)
-			c.sensision.WriteString(gts)
-			gts = core.GetSeriesOutputAttributes(now, class+".inodes.used",
-				fmt.Sprintf("{%v}", core.ToLabels("disk", diskPath)),
-				fmt.Sprintf("{mount=%v}", usage.Path), usage.InodesUsed)
-			c.sensision.WriteString(gts)
-			gts = core.GetSeriesOutputAttributes(now, class+".inodes.total",
-				fmt.Sprintf("{%v}", core.ToLabels("disk", diskPath)),
-				fmt.Sprintf("{mount=%v}", usage.Path), usage.InodesTotal)
-			c.sensision.WriteString(gts)
+		if c.level > 1 {
+			c.samples = append(c.samples,
+				fsSample(now, class+".used", m, m.usage.Used),
+				fsSample(now, class+".total", m, m.usage.Total),
+				fsSample(now, class+".inodes.used", m, m.usage.InodesUsed),
+				fsSample(now, class+".inodes.total", m, m.usage.InodesTotal))
 		}
 	}
 
@@ -153,41 +193,71 @@ func (c *Disk) scrape() error {
 					continue
 				}
 			}
-			gts := core.GetSeriesOutput(now, class+".bytes.read",
-				fmt.Sprintf("{%v}", core.ToLabels("name", name)), stats.ReadBytes)
-			c.sensision.WriteString(gts)
-			gts = core.GetSeriesOutput(now, class+".bytes.write",
-				fmt.Sprintf("{%v}", core.ToLabels("name", name)), stats.WriteBytes)
-			c.sensision.WriteString(gts)
+			labels := core.Labels{"name": name}
+
+			c.samples = append(c.samples,
+				core.NewCounter(now, class+".bytes.read", labels, stats.ReadBytes),
+				core.NewCounter(now, class+".bytes.write", labels, stats.WriteBytes))
 
 			if c.level > 3 {
-				gts = core.GetSeriesOutput(now, class+".io.read",
-					fmt.Sprintf("{%v}", core.ToLabels("name", name)), stats.ReadCount)
-				c.sensision.WriteString(gts)
-				gts = core.GetSeriesOutput(now, class+".io.write",
-					fmt.Sprintf("{%v}", core.ToLabels("name", name)), stats.WriteCount)
-				c.sensision.WriteString(gts)
+				c.samples = append(c.samples,
+					core.NewCounter(now, class+".io.read", labels, stats.ReadCount),
+					core.NewCounter(now, class+".io.write", labels, stats.WriteCount))
 
 				if c.level > 4 {
-					gts = core.GetSeriesOutput(now, class+".io.read.ms",
-						fmt.Sprintf("{%v}", core.ToLabels("name", name)), stats.ReadTime)
-					c.sensision.WriteString(gts)
-					gts = core.GetSeriesOutput(now, class+".io.write.ms",
-						fmt.Sprintf("{%v}", core.ToLabels("name", name)), stats.WriteTime)
-					c.sensision.WriteString(gts)
-					gts = core.GetSeriesOutput(now, class+".io",
-						fmt.Sprintf("{%v}", core.ToLabels("name", name)), stats.IopsInProgress)
-					c.sensision.WriteString(gts)
-					gts = core.GetSeriesOutput(now, class+".io.ms",
-						fmt.Sprintf("{%v}", core.ToLabels("name", name)), stats.IoTime)
-					c.sensision.WriteString(gts)
-					gts = core.GetSeriesOutput(now, class+".io.weighted.ms",
-						fmt.Sprintf("{%v}", core.ToLabels("name", name)), stats.WeightedIO)
-					c.sensision.WriteString(gts)
+					c.samples = append(c.samples,
+						core.NewCounter(now, class+".io.read.ms", labels, stats.ReadTime),
+						core.NewCounter(now, class+".io.write.ms", labels, stats.WriteTime),
+						core.NewGauge(now, class+".io", labels, stats.IopsInProgress),
+						core.NewCounter(now, class+".io.ms", labels, stats.IoTime),
+						core.NewCounter(now, class+".io.weighted.ms", labels, stats.WeightedIO))
 				}
 			}
 		}
 	}
 
+	c.samples = c.rate.Apply(c.samples)
+
 	return nil
 }
+
+// mount is a mounted filesystem and its usage
+type mount struct {
+	disk.PartitionStat
+	usage *disk.UsageStat
+	err   error
+}
+
+func (m mount) readonly() bool {
+	return stringInSlice("ro", strings.Split(m.Opts, ","))
+}
+
+// selected tells whether a filesystem passes the device, mount point and
+// filesystem type filters
+func (c *Disk) selected(p disk.PartitionStat) bool {
+	if len(c.allowedDisks) > 0 {
+		_, diskName := path.Split(p.Device) // return "sda1" from "/dev/sda1"
+		if !stringInSlice(diskName, c.allowedDisks) {
+			log.Debug("Disk " + p.Device + " is blacklisted, skip it")
+			return false
+		}
+	}
+
+	if c.mountpoints != nil && !stringInSlice(p.Mountpoint, c.mountpoints) {
+		return false
+	}
+	if stringInSlice(p.Mountpoint, c.excludeMountpoints) {
+		return false
+	}
+	if c.fstypes != nil && !stringInSlice(p.Fstype, c.fstypes) {
+		return false
+	}
+	return !stringInSlice(p.Fstype, c.excludeFstypes)
+}
+
+// fsSample returns a filesystem gauge labelled by device and mount point, with the filesystem type as attribute
+func fsSample(now int64, class string, m mount, value interface{}) core.Sample {
+	s := core.NewGauge(now, class, core.Labels{"disk": m.Device, "mount": m.Mountpoint}, value)
+	s.Attributes = core.Labels{"fstype": m.Fstype}
+	return s
+}
@@ -0,0 +1,56 @@
+package collectors
+
+import (
+	"testing"
+
+	"github.com/shirou/gopsutil/disk"
+)
+
+func TestDiskSelected(t *testing.T) {
+	root := disk.PartitionStat{Device: "/dev/sda1", Mountpoint: "/", Fstype: "ext4"}
+	srv := disk.PartitionStat{Device: "/dev/sdb1", Mountpoint: "/srv/data", Fstype: "xfs"}
+	nfs := disk.PartitionStat{Device: "nas:/export", Mountpoint: "/mnt/nas", Fstype: "nfs4"}
+	proc := disk.PartitionStat{Device: "proc", Mountpoint: "/proc", Fstype: "proc"}
+	cgroup := disk.PartitionStat{Device: "cgroup2", Mountpoint: "/sys/fs/cgroup", Fstype: "cgroup2"}
+	tmp := disk.PartitionStat{Device: "tmpfs", Mountpoint: "/run", Fstype: "tmpfs"}
+
+	tests := []struct {
+		name string
+		opts map[string]interface{}
+		want map[disk.PartitionStat]bool
+	}{
+		{
+			name: "defaults",
+			want: map[disk.PartitionStat]bool{root: true, srv: true, nfs: true, proc: false, cgroup: false, tmp: false},
+		},
+		{
+			name: "fstypes",
+			opts: map[string]interface{}{"fstypes": []string{"xfs", "nfs4", "tmpfs"}},
+			want: map[disk.PartitionStat]bool{root: false, srv: true, nfs: true, proc: false, tmp: false},
+		},
+		{
+			name: "exclude fstypes replaces the defaults",
+			opts: map[string]interface{}{"exclude-fstypes": []string{"nfs4"}},
+			want: map[disk.PartitionStat]bool{root: true, nfs: false, proc: true, tmp: true},
+		},
+		{
+			name: "mountpoints",
+			opts: map[string]interface{}{"mountpoints": []string{"/", "~^/srv/"}, "exclude-mountpoints": []string{"/srv/data"}},
+			want: map[disk.PartitionStat]bool{root: true, srv: false, nfs: false},
+		},
+		{
+			name: "names",
+			opts: map[string]interface{}{"names": []string{"sdb1"}},
+			want: map[disk.PartitionStat]bool{root: false, srv: true},
+		},
+	}
+
+	for _, tt := range tests {
+		c := NewDisk(1000, 0, tt.opts)
+		for p, want := range tt.want {
+			if got := c.selected(p); got != want {
+				t.Errorf("%s: selected(%s on %s) = %v, want %v", tt.name, p.Fstype, p.Mountpoint, got, want)
+			}
+		}
+	}
+}
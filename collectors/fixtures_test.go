@@ -0,0 +1,76 @@
+package collectors
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ovh/noderig/core"
+)
+
+// fakeTree writes the files under a temporary directory, returning it along
+// with its cleanup function.
+func fakeTree(t *testing.T, files map[string]string) (string, func()) {
+	t.Helper()
+
+	root, err := ioutil.TempDir("", "noderig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root, func() { os.RemoveAll(root) }
+}
+
+// setEnv sets an environment variable, returning the function restoring it
+func setEnv(key, value string) func() {
+	prev, ok := os.LookupEnv(key)
+	os.Setenv(key, value)
+	return func() {
+		if ok {
+			os.Setenv(key, prev)
+		} else {
+			os.Unsetenv(key)
+		}
+	}
+}
+
+// sampleValues indexes the values of samples by class and labels, e.g.
+// os.cgroup.mem.current{cgroup=/a.slice}
+func sampleValues(samples []core.Sample) map[string]interface{} {
+	res := make(map[string]interface{}, len(samples))
+	for _, s := range samples {
+		var labels []string
+		for _, k := range s.Labels.Keys() {
+			labels = append(labels, k+"="+s.Labels[k])
+		}
+		res[s.Class+"{"+strings.Join(labels, ",")+"}"] = s.Value
+	}
+	return res
+}
+
+// checkValues compares the values of samples to the expected ones
+func checkValues(t *testing.T, name string, samples []core.Sample, want map[string]interface{}) {
+	t.Helper()
+
+	got := sampleValues(samples)
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s: %s = %#v, want %#v", name, k, got[k], v)
+		}
+	}
+	for k := range got {
+		if _, ok := want[k]; !ok {
+			t.Errorf("%s: unexpected %s = %#v", name, k, got[k])
+		}
+	}
+}
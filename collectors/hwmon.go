@@ -0,0 +1,232 @@
+package collectors
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ovh/noderig/core"
+	log "github.com/sirupsen/logrus"
+)
+
+// hwmonType is a kind of hwmon sensor
+type hwmonType struct {
+	prefix string // attributes prefix, as temp in temp1_input
+	class  string
+	unit   core.Unit
+	div    float64 // converts the sysfs value into the unit
+	level  uint8
+	name   string
+	label  string // unit shown in help
+}
+
+// hwmonTypes are the reported sensors, in their exposition order
+var hwmonTypes = []hwmonType{
+	{"temp", "os.hwmon.temperature", core.Celsius, 1000, 1, "temperature", "celsius"},
+	{"fan", "os.hwmon.fan", core.NoUnit, 1, 2, "fan speed", "rpm"},
+	{"in", "os.hwmon.voltage", core.Volts, 1000, 2, "voltage", "volts"},
+	{"power", "os.hwmon.power", core.Watts, 1000000, 2, "power", "watts"},
+}
+
+// hwmon thresholds, exposed from level 3
+var hwmonThresholds = []string{"max", "crit"}
+
+// sensor input attributes, power meters exposing an average only
+var hwmonInputRegexp = regexp.MustCompile(`^(temp|fan|in|power)([0-9]+)_(input|average)$`)
+
+func init() {
+	for _, t := range hwmonTypes {
+		core.Describe(t.class, t.unit, fmt.Sprintf("Hardware sensor %s (%s)", t.name, t.label))
+		for _, threshold := range hwmonThresholds {
+			core.Describe(t.class+"."+threshold, t.unit, fmt.Sprintf("Hardware sensor %s %s threshold (%s)", threshold, t.name, t.label))
+		}
+	}
+}
+
+// Hwmon collects the hardware sensors of /sys/class/hwmon
+type Hwmon struct {
+	root  string
+	chips []string
+
+	mutex   sync.RWMutex
+	samples []core.Sample
+	level   uint8
+}
+
+// NewHwmon returns an initialized Hwmon collector.
+func NewHwmon(period uint, level uint8, opts interface{}) *Hwmon {
+	c := &Hwmon{
+		root:  stringOption(opts, "root", sysPath("class", "hwmon")),
+		chips: stringsOption(opts, "chips"),
+		level: level,
+	}
+
+	if level == 0 {
+		return c
+	}
+
+	if _, err := os.Stat(c.root); err != nil {
+		log.Warnf("No hwmon sensors in %s, hwmon collector disabled", c.root)
+		return c
+	}
+
+	tick := time.NewTicker(time.Duration(period) * time.Millisecond)
+	go func() {
+		for range tick.C {
+			if err := c.scrape(); err != nil {
+				log.Error(err)
+			}
+		}
+	}()
+
+	return c
+}
+
+// Samples delivers metrics.
+func (c *Hwmon) Samples() []core.Sample {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	return append([]core.Sample(nil), c.samples...)
+}
+
+func (c *Hwmon) scrape() error {
+	entries, err := ioutil.ReadDir(c.root)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UnixNano() / 1000
+	var samples []core.Sample
+	for _, e := range entries {
+		samples = append(samples, c.scrapeChip(now, filepath.Join(c.root, e.Name()))...)
+	}
+
+	// protect consistency
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.samples = samples
+
+	return nil
+}
+
+// hwmonSensor is a sensor input attribute of a chip
+type hwmonSensor struct {
+	typ   int // index in hwmonTypes
+	index int
+	input string
+}
+
+// scrapeChip reads the sensors of a hwmon chip, labelled by the chip name,
+// its device, the sensor attribute name and the sensor label when set.
+func (c *Hwmon) scrapeChip(now int64, dir string) []core.Sample {
+	device, _ := os.Readlink(filepath.Join(dir, "device"))
+
+	// old drivers expose their attributes on the device
+	name, err := readString(filepath.Join(dir, "name"))
+	if err != nil {
+		dir = filepath.Join(dir, "device")
+		if name, err = readString(filepath.Join(dir, "name")); err != nil {
+			return nil
+		}
+	}
+	if c.chips != nil && !stringInSlice(name, c.chips) {
+		return nil
+	}
+
+	labels := core.Labels{"chip": name}
+	if device != "" {
+		labels["device"] = filepath.Base(device)
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	sensors := make(map[string]hwmonSensor)
+	for _, f := range files {
+		m := hwmonInputRegexp.FindStringSubmatch(f.Name())
+		if m == nil {
+			continue
+		}
+
+		sensor := m[1] + m[2]
+		if s, ok := sensors[sensor]; ok && strings.HasSuffix(s.input, "_input") {
+			continue // prefer the instantaneous value
+		}
+		index, _ := strconv.Atoi(m[2])
+		for i, t := range hwmonTypes {
+			if t.prefix == m[1] {
+				sensors[sensor] = hwmonSensor{typ: i, index: index, input: f.Name()}
+			}
+		}
+	}
+
+	ordered := make([]hwmonSensor, 0, len(sensors))
+	for _, s := range sensors {
+		ordered = append(ordered, s)
+	}
+	sort.Slice(ordered, func(i, j int) bool {
+		if ordered[i].typ != ordered[j].typ {
+			return ordered[i].typ < ordered[j].typ
+		}
+		return ordered[i].index < ordered[j].index
+	})
+
+	var samples []core.Sample
+	for _, s := range ordered {
+		t := hwmonTypes[s.typ]
+		if t.level > c.level {
+			continue
+		}
+
+		value, ok := readSensor(filepath.Join(dir, s.input))
+		if !ok {
+			continue // sensor not connected
+		}
+
+		sensor := t.prefix + strconv.Itoa(s.index)
+		sensorLabels := labels.Copy()
+		sensorLabels["sensor"] = sensor
+		if label, err := readString(filepath.Join(dir, sensor+"_label")); err == nil && label != "" {
+			sensorLabels["label"] = label
+		}
+
+		samples = append(samples, core.NewGauge(now, t.class, sensorLabels, value/t.div))
+
+		if c.level > 2 {
+			for _, threshold := range hwmonThresholds {
+				if v, ok := readSensor(filepath.Join(dir, sensor+"_"+threshold)); ok {
+					samples = append(samples, core.NewGauge(now, t.class+"."+threshold, sensorLabels, v/t.div))
+				}
+			}
+		}
+	}
+
+	return samples
+}
+
+// readSensor reads a sensor attribute, temperatures being signed
+func readSensor(path string) (float64, bool) {
+	content, err := readString(path)
+	if err != nil {
+		return 0, false
+	}
+	v, err := strconv.ParseInt(content, 10, 64)
+	return float64(v), err == nil
+}
+
+// readString reads a single line file
+func readString(path string) (string, error) {
+	content, err := ioutil.ReadFile(path)
+	return strings.TrimSpace(string(content)), err
+}
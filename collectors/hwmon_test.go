@@ -0,0 +1,97 @@
+package collectors
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHwmonScrape(t *testing.T) {
+	root, cleanup := fakeTree(t, map[string]string{
+		// chip with labelled sensors and thresholds
+		"class/hwmon/hwmon0/name":        "k10temp\n",
+		"class/hwmon/hwmon0/temp1_input": "45500\n",
+		"class/hwmon/hwmon0/temp1_label": "Tctl\n",
+		"class/hwmon/hwmon0/temp1_max":   "70000\n",
+		"class/hwmon/hwmon0/temp1_crit":  "95000\n",
+		"class/hwmon/hwmon0/temp2_input": "-5000\n",
+
+		// old driver exposing its attributes on the device
+		"devices/platform/it87.656/name":       "it8728\n",
+		"devices/platform/it87.656/fan1_input": "1200\n",
+		"devices/platform/it87.656/fan2_input": "\n",
+		"devices/platform/it87.656/in0_input":  "1104\n",
+		"devices/platform/it87.656/in0_label":  "\n",
+
+		// power meter exposing an average only
+		"class/hwmon/hwmon2/name":           "amdgpu\n",
+		"class/hwmon/hwmon2/power1_average": "15250000\n",
+
+		// unnamed chip
+		"class/hwmon/hwmon3/temp1_input": "30000\n",
+	})
+	defer cleanup()
+
+	links := map[string]string{
+		"class/hwmon/hwmon0/device": "../../../devices/pci/0000:00:18.3",
+		"class/hwmon/hwmon1/device": "../../../devices/platform/it87.656",
+	}
+	for link, target := range links {
+		path := filepath.Join(root, link)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink(target, path); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	k10temp := "chip=k10temp,device=0000:00:18.3"
+	it87 := "chip=it8728,device=it87.656"
+
+	tests := []struct {
+		name  string
+		level uint8
+		chips []string
+		want  map[string]interface{}
+	}{
+		{
+			name:  "level 1",
+			level: 1,
+			want: map[string]interface{}{
+				"os.hwmon.temperature{" + k10temp + ",label=Tctl,sensor=temp1}": 45.5,
+				"os.hwmon.temperature{" + k10temp + ",sensor=temp2}":            -5.0,
+			},
+		},
+		{
+			name:  "level 3",
+			level: 3,
+			want: map[string]interface{}{
+				"os.hwmon.temperature{" + k10temp + ",label=Tctl,sensor=temp1}":      45.5,
+				"os.hwmon.temperature.max{" + k10temp + ",label=Tctl,sensor=temp1}":  70.0,
+				"os.hwmon.temperature.crit{" + k10temp + ",label=Tctl,sensor=temp1}": 95.0,
+				"os.hwmon.temperature{" + k10temp + ",sensor=temp2}":                 -5.0,
+				"os.hwmon.fan{" + it87 + ",sensor=fan1}":                             1200.0,
+				"os.hwmon.voltage{" + it87 + ",sensor=in0}":                          1.104,
+				"os.hwmon.power{chip=amdgpu,sensor=power1}":                          15.25,
+			},
+		},
+		{
+			name:  "chips",
+			level: 2,
+			chips: []string{"~^it87"},
+			want: map[string]interface{}{
+				"os.hwmon.fan{" + it87 + ",sensor=fan1}":    1200.0,
+				"os.hwmon.voltage{" + it87 + ",sensor=in0}": 1.104,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		c := &Hwmon{root: filepath.Join(root, "class", "hwmon"), chips: tt.chips, level: tt.level}
+		if err := c.scrape(); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		checkValues(t, tt.name, c.Samples(), tt.want)
+	}
+}
@@ -1,7 +1,6 @@
 package collectors
 
 import (
-	"bytes"
 	"sync"
 	"time"
 
@@ -10,11 +9,17 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
+func init() {
+	core.Describe("os.load1", core.NoUnit, "Load average over 1 minute")
+	core.Describe("os.load5", core.NoUnit, "Load average over 5 minutes")
+	core.Describe("os.load15", core.NoUnit, "Load average over 15 minutes")
+}
+
 // Load collects load related metrics
 type Load struct {
-	mutex     sync.RWMutex
-	sensision bytes.Buffer
-	level     uint8
+	mutex   sync.RWMutex
+	samples []core.Sample
+	level   uint8
 }
 
 // NewLoad returns an initialized Load collector.
@@ -39,14 +44,12 @@ func NewLoad(period uint, level uint8) *Load {
 	return c
 }
 
-// Metrics delivers metrics.
-func (c *Load) Metrics() *bytes.Buffer {
+// Samples delivers metrics.
+func (c *Load) Samples() []core.Sample {
 	c.mutex.RLock()
 	defer c.mutex.RUnlock()
 
-	var res bytes.Buffer
-	res.Write(c.sensision.Bytes())
-	return &res
+	return append([]core.Sample(nil), c.samples...)
 }
 
 func (c *Load) scrape() error {
@@ -60,21 +63,17 @@ func (c *Load) scrape() error {
 	defer c.mutex.Unlock()
 
 	// Delete previous metrics
-	c.sensision.Reset()
+	c.samples = nil
 
 	class := "os.load"
 
 	now := time.Now().UnixNano() / 1000
 
-	gts := core.GetSeriesOutput(now, class+"1", "{}", avg.Load1)
-	c.sensision.WriteString(gts)
+	c.samples = append(c.samples, core.NewGauge(now, class+"1", nil, avg.Load1))
 
 	if c.level > 1 {
-		gts := core.GetSeriesOutput(now, class+"5", "{}", avg.Load5)
-		c.sensision.WriteString(gts)
-
-		gts = core.GetSeriesOutput(now, class+"15", "{}", avg.Load15)
-		c.sensision.WriteString(gts)
+		c.samples = append(c.samples, core.NewGauge(now, class+"5", nil, avg.Load5))
+		c.samples = append(c.samples, core.NewGauge(now, class+"15", nil, avg.Load15))
 	}
 
 	return nil
@@ -1,7 +1,7 @@
 package collectors
 
 import (
-	"bytes"
+	"strings"
 	"sync"
 	"time"
 
@@ -10,17 +10,108 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
+func init() {
+	core.Describe("os.mem", core.Percent, "Percentage of memory used")
+	core.Describe("os.swap", core.Percent, "Percentage of swap used")
+	core.Describe("os.mem.used", core.Bytes, "Used memory (bytes)")
+	core.Describe("os.mem.total", core.Bytes, "Total memory (bytes)")
+	core.Describe("os.swap.used", core.Bytes, "Used swap (bytes)")
+	core.Describe("os.swap.total", core.Bytes, "Total swap (bytes)")
+	core.Describe("os.mem.free", core.Bytes, "Free memory (bytes)")
+	core.Describe("os.mem.buffers", core.Bytes, "Buffers memory (bytes)")
+	core.Describe("os.mem.cached", core.Bytes, "Cached memory (bytes)")
+	for _, m := range meminfoStats {
+		core.Describe(m.class, m.unit, m.help)
+	}
+	core.Describe("os.mem.pagecache", core.Percent, "Percentage of memory used by the page cache")
+	core.Describe("os.mem.pagecache.dirty", core.Percent, "Percentage of the page cache waiting to be written back")
+	for _, v := range vmstatStats {
+		core.Describe("os.vmstat."+v[0], core.NoUnit, v[1])
+	}
+	for _, m := range nodeMeminfoStats {
+		core.Describe(m.class, m.unit, m.help)
+	}
+	for _, v := range numastatStats {
+		core.Describe(v[1], core.NoUnit, v[2])
+	}
+}
+
+// meminfoStat maps a /proc/meminfo field to a series
+type meminfoStat struct {
+	key   string
+	class string
+	unit  core.Unit
+	help  string
+}
+
+// meminfoStats are exposed from level 4
+var meminfoStats = []meminfoStat{
+	{"MemAvailable", "os.mem.available", core.Bytes, "Memory available for new workloads without swapping (bytes)"},
+	{"Slab", "os.mem.slab", core.Bytes, "Kernel slab memory (bytes)"},
+	{"SReclaimable", "os.mem.slab.reclaimable", core.Bytes, "Reclaimable kernel slab memory (bytes)"},
+	{"SUnreclaim", "os.mem.slab.unreclaimable", core.Bytes, "Unreclaimable kernel slab memory (bytes)"},
+	{"Shmem", "os.mem.shmem", core.Bytes, "Shared memory and tmpfs (bytes)"},
+	{"Dirty", "os.mem.dirty", core.Bytes, "Memory waiting to be written back to disk (bytes)"},
+	{"Writeback", "os.mem.writeback", core.Bytes, "Memory being written back to disk (bytes)"},
+	{"Committed_AS", "os.mem.committed", core.Bytes, "Memory committed by processes (bytes)"},
+	{"CommitLimit", "os.mem.commit.limit", core.Bytes, "Memory commit limit (bytes)"},
+	{"HugePages_Total", "os.mem.hugepages.total", core.NoUnit, "Huge pages pool size"},
+	{"HugePages_Free", "os.mem.hugepages.free", core.NoUnit, "Free huge pages"},
+	{"HugePages_Rsvd", "os.mem.hugepages.reserved", core.NoUnit, "Reserved huge pages"},
+	{"HugePages_Surp", "os.mem.hugepages.surplus", core.NoUnit, "Surplus huge pages"},
+	{"Hugepagesize", "os.mem.hugepages.size", core.Bytes, "Huge page size (bytes)"},
+}
+
+// nodeMeminfoStats are the per NUMA node memory series exposed from level 4
+var nodeMeminfoStats = []meminfoStat{
+	{"MemTotal", "os.mem.node.total", core.Bytes, "Total memory of the NUMA node (bytes)"},
+	{"MemFree", "os.mem.node.free", core.Bytes, "Free memory of the NUMA node (bytes)"},
+	{"MemUsed", "os.mem.node.used", core.Bytes, "Used memory of the NUMA node (bytes)"},
+	{"FilePages", "os.mem.node.cached", core.Bytes, "Page cache memory of the NUMA node (bytes)"},
+	{"Shmem", "os.mem.node.shmem", core.Bytes, "Shared memory and tmpfs of the NUMA node (bytes)"},
+	{"Slab", "os.mem.node.slab", core.Bytes, "Kernel slab memory of the NUMA node (bytes)"},
+	{"HugePages_Total", "os.mem.node.hugepages.total", core.NoUnit, "Huge pages pool size of the NUMA node"},
+	{"HugePages_Free", "os.mem.node.hugepages.free", core.NoUnit, "Free huge pages of the NUMA node"},
+}
+
+// numastatStats are the per NUMA node allocation counters exposed from level 5
+var numastatStats = [][3]string{
+	{"numa_hit", "os.numa.hit", "Pages allocated on the intended NUMA node"},
+	{"numa_miss", "os.numa.miss", "Pages allocated on the NUMA node despite the process preferring another one"},
+	{"numa_foreign", "os.numa.foreign", "Pages intended for the NUMA node but allocated on another one"},
+	{"interleave_hit", "os.numa.interleave.hit", "Interleaved pages allocated on the intended NUMA node"},
+	{"local_node", "os.numa.local", "Pages allocated on the NUMA node while the process ran on it"},
+	{"other_node", "os.numa.other", "Pages allocated on the NUMA node while the process ran on another one"},
+}
+
+// vmstatStats are the /proc/vmstat counters exposed from level 5
+var vmstatStats = [][2]string{
+	{"pgfault", "Page faults"},
+	{"pgmajfault", "Major page faults"},
+	{"pgpgin", "Pages paged in from disk"},
+	{"pgpgout", "Pages paged out to disk"},
+	{"pswpin", "Pages swapped in"},
+	{"pswpout", "Pages swapped out"},
+	{"allocstall", "Direct reclaim stalls"},
+	{"compact_stall", "Direct compaction stalls"},
+	{"compact_fail", "Direct compaction failures"},
+	{"compact_success", "Direct compaction successes"},
+	{"oom_kill", "Processes killed by the OOM killer"},
+}
+
 // Memory collects memory related metrics
 type Memory struct {
-	mutex     sync.RWMutex
-	sensision bytes.Buffer
-	level     uint8
+	mutex   sync.RWMutex
+	samples []core.Sample
+	rate    *core.Rate
+	level   uint8
 }
 
 // NewMemory returns an initialized Memory collector.
-func NewMemory(period uint, level uint8) *Memory {
+func NewMemory(period uint, level uint8, opts interface{}) *Memory {
 	c := &Memory{
 		level: level,
+		rate:  core.NewRate(counterMode(opts)),
 	}
 
 	if level == 0 {
@@ -39,14 +130,12 @@ func NewMemory(period uint, level uint8) *Memory {
 	return c
 }
 
-// Metrics delivers metrics.
-func (c *Memory) Metrics() *bytes.Buffer {
+// Samples delivers metrics.
+func (c *Memory) Samples() []core.Sample {
 	c.mutex.RLock()
 	defer c.mutex.RUnlock()
 
-	var res bytes.Buffer
-	res.Write(c.sensision.Bytes())
-	return &res
+	return append([]core.Sample(nil), c.samples...)
 }
 
 func (c *Memory) scrape() error {
@@ -60,42 +149,109 @@ func (c *Memory) scrape() error {
 		return err
 	}
 
+	var meminfo, vmstat map[string]uint64
+	var nodes []string
+	nodeMeminfo := make(map[string]map[string]uint64)
+	numastat := make(map[string]map[string]uint64)
+	if c.level > 3 {
+		if meminfo, err = readMeminfo(procPath("meminfo")); err != nil {
+			return err
+		}
+
+		nodes = numaNodes()
+		for _, node := range nodes {
+			if nodeMeminfo[node], err = readMeminfo(numaPath(node, "meminfo")); err != nil {
+				return err
+			}
+			if c.level > 4 {
+				if numastat[node], err = readFlatKeyed(numaPath(node, "numastat")); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if c.level > 4 {
+		if vmstat, err = readFlatKeyed(procPath("vmstat")); err != nil {
+			return err
+		}
+
+		// recent kernels split allocation stalls by zone
+		if _, ok := vmstat["allocstall"]; !ok {
+			for k, v := range vmstat {
+				if strings.HasPrefix(k, "allocstall_") {
+					vmstat["allocstall"] += v
+				}
+			}
+		}
+	}
+
 	// protect consistency
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
 	// Delete previous metrics
-	c.sensision.Reset()
+	c.samples = nil
 
 	memClass := "os.mem"
 	swapClass := "os.swap"
 
 	now := time.Now().UnixNano() / 1000
 
-	gts := core.GetSeriesOutput(now, memClass, "{}", virt.UsedPercent)
-	c.sensision.WriteString(gts)
-
-	gts = core.GetSeriesOutput(now, swapClass, "{}", swap.UsedPercent)
-	c.sensision.WriteString(gts)
+	c.samples = append(c.samples, core.NewGauge(now, memClass, nil, virt.UsedPercent))
+	c.samples = append(c.samples, core.NewGauge(now, swapClass, nil, swap.UsedPercent))
 
 	if c.level > 1 {
-		gts := core.GetSeriesOutput(now, memClass+".used", "{}", virt.Used)
-		c.sensision.WriteString(gts)
-		gts = core.GetSeriesOutput(now, memClass+".total", "{}", virt.Total)
-		c.sensision.WriteString(gts)
-		gts = core.GetSeriesOutput(now, swapClass+".used", "{}", swap.Used)
-		c.sensision.WriteString(gts)
-		gts = core.GetSeriesOutput(now, swapClass+".total", "{}", swap.Total)
-		c.sensision.WriteString(gts)
+		c.samples = append(c.samples, core.NewGauge(now, memClass+".used", nil, virt.Used))
+		c.samples = append(c.samples, core.NewGauge(now, memClass+".total", nil, virt.Total))
+		c.samples = append(c.samples, core.NewGauge(now, swapClass+".used", nil, swap.Used))
+		c.samples = append(c.samples, core.NewGauge(now, swapClass+".total", nil, swap.Total))
 	}
 	if c.level > 2 {
-		gts := core.GetSeriesOutput(now, memClass+".free", "{}", virt.Free)
-		c.sensision.WriteString(gts)
-		gts = core.GetSeriesOutput(now, memClass+".buffers", "{}", virt.Buffers)
-		c.sensision.WriteString(gts)
-		gts = core.GetSeriesOutput(now, memClass+".cached", "{}", virt.Cached)
-		c.sensision.WriteString(gts)
+		c.samples = append(c.samples, core.NewGauge(now, memClass+".free", nil, virt.Free))
+		c.samples = append(c.samples, core.NewGauge(now, memClass+".buffers", nil, virt.Buffers))
+		c.samples = append(c.samples, core.NewGauge(now, memClass+".cached", nil, virt.Cached))
+	}
+	if c.level > 3 {
+		for _, m := range meminfoStats {
+			if v, ok := meminfo[m.key]; ok {
+				c.samples = append(c.samples, core.NewGauge(now, m.class, nil, v))
+			}
+		}
+
+		if meminfo["MemTotal"] > 0 {
+			ratio := float64(meminfo["Cached"]) / float64(meminfo["MemTotal"]) * 100
+			c.samples = append(c.samples, core.NewGauge(now, memClass+".pagecache", nil, ratio))
+		}
+		if meminfo["Cached"] > 0 {
+			ratio := float64(meminfo["Dirty"]) / float64(meminfo["Cached"]) * 100
+			c.samples = append(c.samples, core.NewGauge(now, memClass+".pagecache.dirty", nil, ratio))
+		}
+
+		for _, node := range nodes {
+			for _, m := range nodeMeminfoStats {
+				if v, ok := nodeMeminfo[node][m.key]; ok {
+					c.samples = append(c.samples, core.NewGauge(now, m.class, core.Labels{"node": node}, v))
+				}
+			}
+		}
 	}
+	if c.level > 4 {
+		for _, v := range vmstatStats {
+			if value, ok := vmstat[v[0]]; ok {
+				c.samples = append(c.samples, core.NewCounter(now, "os.vmstat."+v[0], nil, value))
+			}
+		}
+
+		for _, node := range nodes {
+			for _, v := range numastatStats {
+				if value, ok := numastat[node][v[0]]; ok {
+					c.samples = append(c.samples, core.NewCounter(now, v[1], core.Labels{"node": node}, value))
+				}
+			}
+		}
+	}
+
+	c.samples = c.rate.Apply(c.samples)
 
 	return nil
 }
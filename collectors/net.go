@@ -1,8 +1,6 @@
 package collectors
 
 import (
-	"bytes"
-	"fmt"
 	"regexp"
 	"strings"
 	"sync"
@@ -13,11 +11,19 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
+func init() {
+	core.Describe("os.net.bytes", core.Bytes, "Network interfaces bytes count (bytes)")
+	core.Describe("os.net.packets", core.NoUnit, "Network interfaces packet count (packets)")
+	core.Describe("os.net.errs", core.NoUnit, "Network interfaces error count (errors)")
+	core.Describe("os.net.dropped", core.NoUnit, "Network interfaces drop count (drops)")
+}
+
 // Net collects network related metrics
 type Net struct {
 	interfaces []string
 	mutex      sync.RWMutex
-	sensision  bytes.Buffer
+	samples    []core.Sample
+	rate       *core.Rate
 	level      uint8
 	period     uint
 }
@@ -47,6 +53,7 @@ func NewNet(period uint, level uint8, opts interface{}) *Net {
 		level:      level,
 		period:     period,
 		interfaces: ifaces,
+		rate:       core.NewRate(counterMode(opts)),
 	}
 
 	if level == 0 {
@@ -65,14 +72,12 @@ func NewNet(period uint, level uint8, opts interface{}) *Net {
 	return c
 }
 
-// Metrics delivers metrics.
-func (c *Net) Metrics() *bytes.Buffer {
+// Samples delivers metrics.
+func (c *Net) Samples() []core.Sample {
 	c.mutex.RLock()
 	defer c.mutex.RUnlock()
 
-	var res bytes.Buffer
-	res.Write(c.sensision.Bytes())
-	return &res
+	return append([]core.Sample(nil), c.samples...)
 }
 
 func (c *Net) scrape() error {
@@ -91,24 +96,20 @@ func (c *Net) scrape() error {
 		in += cnt.BytesRecv
 		out += cnt.BytesSent
 	}
-	in /= uint64(c.period / 1000)
-	out /= uint64(c.period / 1000)
 
 	// protect consistency
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
-	c.sensision.Reset()
+	c.samples = nil
 
 	class := "os.net.bytes"
 	now := time.Now().UnixNano() / 1000
 
 	if c.level == 1 {
-		gts := core.GetSeriesOutput(now, class, fmt.Sprintf("{%v}", core.ToLabels("direction", "in")), in)
-		c.sensision.WriteString(gts)
-
-		gts = core.GetSeriesOutput(now, class, fmt.Sprintf("{%v}", core.ToLabels("direction", "out")), out)
-		c.sensision.WriteString(gts)
+		c.samples = append(c.samples,
+			core.NewCounter(now, class, core.Labels{"direction": "in"}, in),
+			core.NewCounter(now, class, core.Labels{"direction": "out"}, out))
 	}
 
 	if c.level > 1 {
@@ -119,13 +120,9 @@ func (c *Net) scrape() error {
 				continue
 			}
 
-			gts := core.GetSeriesOutput(now, class,
-				fmt.Sprintf("{%v,%v}", core.ToLabels("iface", cnt.Name), core.ToLabels("direction", "in")), cnt.BytesRecv)
-			c.sensision.WriteString(gts)
-
-			gts = core.GetSeriesOutput(now, class,
-				fmt.Sprintf("{%v,%v}", core.ToLabels("iface", cnt.Name), core.ToLabels("direction", "out")), cnt.BytesSent)
-			c.sensision.WriteString(gts)
+			c.samples = append(c.samples,
+				core.NewCounter(now, class, core.Labels{"iface": cnt.Name, "direction": "in"}, cnt.BytesRecv),
+				core.NewCounter(now, class, core.Labels{"iface": cnt.Name, "direction": "out"}, cnt.BytesSent))
 		}
 	}
 
@@ -137,32 +134,21 @@ func (c *Net) scrape() error {
 				continue
 			}
 
-			gts := core.GetSeriesOutput(now, "os.net.packets",
-				fmt.Sprintf("{%v,%v}", core.ToLabels("iface", cnt.Name), core.ToLabels("direction", "in")), cnt.PacketsRecv)
-			c.sensision.WriteString(gts)
-
-			gts = core.GetSeriesOutput(now, "os.net.packets",
-				fmt.Sprintf("{%v,%v}", core.ToLabels("iface", cnt.Name), core.ToLabels("direction", "out")), cnt.PacketsSent)
-			c.sensision.WriteString(gts)
+			in := core.Labels{"iface": cnt.Name, "direction": "in"}
+			out := core.Labels{"iface": cnt.Name, "direction": "out"}
 
-			gts = core.GetSeriesOutput(now, "os.net.errs",
-				fmt.Sprintf("{%v,%v}", core.ToLabels("iface", cnt.Name), core.ToLabels("direction", "in")), cnt.Errin)
-			c.sensision.WriteString(gts)
-
-			gts = core.GetSeriesOutput(now, "os.net.errs",
-				fmt.Sprintf("{%v,%v}", core.ToLabels("iface", cnt.Name), core.ToLabels("direction", "out")), cnt.Errout)
-			c.sensision.WriteString(gts)
-
-			gts = core.GetSeriesOutput(now, "os.net.dropped",
-				fmt.Sprintf("{%v,%v}", core.ToLabels("iface", cnt.Name), core.ToLabels("direction", "in")), cnt.Dropin)
-			c.sensision.WriteString(gts)
-
-			gts = core.GetSeriesOutput(now, "os.net.dropped",
-				fmt.Sprintf("{%v,%v}", core.ToLabels("iface", cnt.Name), core.ToLabels("direction", "out")), cnt.Dropout)
-			c.sensision.WriteString(gts)
+			c.samples = append(c.samples,
+				core.NewCounter(now, "os.net.packets", in, cnt.PacketsRecv),
+				core.NewCounter(now, "os.net.packets", out, cnt.PacketsSent),
+				core.NewCounter(now, "os.net.errs", in, cnt.Errin),
+				core.NewCounter(now, "os.net.errs", out, cnt.Errout),
+				core.NewCounter(now, "os.net.dropped", in, cnt.Dropin),
+				core.NewCounter(now, "os.net.dropped", out, cnt.Dropout))
 		}
 	}
 
+	c.samples = c.rate.Apply(c.samples)
+
 	return nil
 }
 
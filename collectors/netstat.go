@@ -0,0 +1,275 @@
+package collectors
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ovh/noderig/core"
+	log "github.com/sirupsen/logrus"
+)
+
+// netstatStat maps a kernel statistic to a series
+type netstatStat struct {
+	section string // e.g. Tcp in /proc/net/snmp, TcpExt in /proc/net/netstat, TCP in /proc/net/sockstat
+	key     string
+	class   string
+	kind    core.Kind
+	level   uint8
+	help    string
+}
+
+// netstatStats are read from /proc/net/snmp and /proc/net/netstat
+var netstatStats = []netstatStat{
+	{"Tcp", "CurrEstab", "os.net.tcp.established", core.Gauge, 1, "TCP connections in established or close wait state"},
+	{"Tcp", "RetransSegs", "os.net.tcp.retrans", core.Counter, 1, "TCP segments retransmitted"},
+	{"TcpExt", "ListenOverflows", "os.net.tcp.listen.overflows", core.Counter, 1, "TCP connections dropped on a full accept queue"},
+	{"TcpExt", "ListenDrops", "os.net.tcp.listen.drops", core.Counter, 1, "TCP connections dropped while listening"},
+	{"Udp", "InErrors", "os.net.udp.errors", core.Counter, 1, "UDP datagrams receive errors"},
+	{"Udp", "RcvbufErrors", "os.net.udp.rcvbuf.errors", core.Counter, 1, "UDP datagrams dropped on a full receive buffer"},
+
+	{"Tcp", "ActiveOpens", "os.net.tcp.active.opens", core.Counter, 2, "TCP connections actively opened"},
+	{"Tcp", "PassiveOpens", "os.net.tcp.passive.opens", core.Counter, 2, "TCP connections passively opened"},
+	{"Tcp", "AttemptFails", "os.net.tcp.attempt.fails", core.Counter, 2, "TCP connection attempts failed"},
+	{"Tcp", "EstabResets", "os.net.tcp.estab.resets", core.Counter, 2, "TCP established connections reset"},
+	{"Tcp", "InSegs", "os.net.tcp.in.segs", core.Counter, 2, "TCP segments received"},
+	{"Tcp", "OutSegs", "os.net.tcp.out.segs", core.Counter, 2, "TCP segments sent"},
+	{"Tcp", "InErrs", "os.net.tcp.in.errs", core.Counter, 2, "TCP segments received in error"},
+	{"Tcp", "OutRsts", "os.net.tcp.out.rsts", core.Counter, 2, "TCP resets sent"},
+	{"TcpExt", "TCPTimeouts", "os.net.tcp.timeouts", core.Counter, 2, "TCP retransmission timeouts"},
+	{"TcpExt", "TCPSynRetrans", "os.net.tcp.syn.retrans", core.Counter, 2, "TCP SYN and SYN/ACK retransmitted"},
+	{"TcpExt", "SyncookiesSent", "os.net.tcp.syncookies.sent", core.Counter, 2, "TCP SYN cookies sent"},
+	{"Udp", "InDatagrams", "os.net.udp.in.datagrams", core.Counter, 2, "UDP datagrams received"},
+	{"Udp", "OutDatagrams", "os.net.udp.out.datagrams", core.Counter, 2, "UDP datagrams sent"},
+	{"Udp", "NoPorts", "os.net.udp.no.ports", core.Counter, 2, "UDP datagrams received on a closed port"},
+	{"Udp", "SndbufErrors", "os.net.udp.sndbuf.errors", core.Counter, 2, "UDP datagrams dropped on a full send buffer"},
+}
+
+// sockstatStats are read from /proc/net/sockstat
+var sockstatStats = []netstatStat{
+	{"TCP", "tw", "os.net.tcp.timewait", core.Gauge, 1, "TCP sockets in time wait state"},
+	{"TCP", "inuse", "os.net.tcp.inuse", core.Gauge, 2, "TCP sockets in use"},
+	{"TCP", "orphan", "os.net.tcp.orphan", core.Gauge, 2, "TCP orphaned sockets"},
+	{"TCP", "alloc", "os.net.tcp.alloc", core.Gauge, 2, "TCP allocated sockets"},
+	{"UDP", "inuse", "os.net.udp.inuse", core.Gauge, 2, "UDP sockets in use"},
+}
+
+// tcpStates are the socket states of /proc/net/tcp, indexed by their code
+var tcpStates = []string{"", "established", "syn_sent", "syn_recv", "fin_wait1", "fin_wait2", "time_wait",
+	"close", "close_wait", "last_ack", "listen", "closing", "new_syn_recv"}
+
+func init() {
+	for _, s := range append(netstatStats, sockstatStats...) {
+		core.Describe(s.class, core.NoUnit, s.help)
+	}
+	core.Describe("os.net.tcp.mem", core.Bytes, "TCP sockets buffers memory (bytes)")
+	core.Describe("os.net.udp.mem", core.Bytes, "UDP sockets buffers memory (bytes)")
+	core.Describe("os.net.tcp.connections", core.NoUnit, "TCP sockets by state")
+}
+
+// Netstat collects TCP and UDP stack statistics
+type Netstat struct {
+	mutex   sync.RWMutex
+	samples []core.Sample
+	rate    *core.Rate
+	level   uint8
+}
+
+// NewNetstat returns an initialized Netstat collector.
+func NewNetstat(period uint, level uint8, opts interface{}) *Netstat {
+	c := &Netstat{
+		level: level,
+		rate:  core.NewRate(counterMode(opts)),
+	}
+
+	if level == 0 {
+		return c
+	}
+
+	tick := time.NewTicker(time.Duration(period) * time.Millisecond)
+	go func() {
+		for range tick.C {
+			if err := c.scrape(); err != nil {
+				log.Error(err)
+			}
+		}
+	}()
+
+	return c
+}
+
+// Samples delivers metrics.
+func (c *Netstat) Samples() []core.Sample {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	return append([]core.Sample(nil), c.samples...)
+}
+
+func (c *Netstat) scrape() error {
+	stats, err := readProtoStats(procPath("net", "snmp"))
+	if err != nil {
+		return err
+	}
+	ext, err := readProtoStats(procPath("net", "netstat"))
+	if err != nil {
+		return err
+	}
+	for section, values := range ext {
+		stats[section] = values
+	}
+
+	sockstat, err := readSockstat(procPath("net", "sockstat"))
+	if err != nil {
+		return err
+	}
+
+	var states []uint64
+	if c.level > 2 {
+		states = make([]uint64, len(tcpStates))
+		for _, file := range []string{"tcp", "tcp6"} {
+			if err := countTCPStates(procPath("net", file), states); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+
+	now := time.Now().UnixNano() / 1000
+	var samples []core.Sample
+
+	for _, s := range netstatStats {
+		if v, ok := stats[s.section][s.key]; ok && s.level <= c.level {
+			samples = append(samples, netstatSample(now, s, v))
+		}
+	}
+	for _, s := range sockstatStats {
+		if v, ok := sockstat[s.section][s.key]; ok && s.level <= c.level {
+			samples = append(samples, netstatSample(now, s, v))
+		}
+	}
+
+	if c.level > 1 {
+		// sockstat memory is expressed in pages
+		page := int64(os.Getpagesize())
+		if v, ok := sockstat["TCP"]["mem"]; ok {
+			samples = append(samples, core.NewGauge(now, "os.net.tcp.mem", nil, v*page))
+		}
+		if v, ok := sockstat["UDP"]["mem"]; ok {
+			samples = append(samples, core.NewGauge(now, "os.net.udp.mem", nil, v*page))
+		}
+	}
+
+	for code, count := range states {
+		if tcpStates[code] != "" {
+			samples = append(samples, core.NewGauge(now, "os.net.tcp.connections", core.Labels{"state": tcpStates[code]}, count))
+		}
+	}
+
+	// protect consistency
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.samples = c.rate.Apply(samples)
+
+	return nil
+}
+
+func netstatSample(now int64, s netstatStat, v int64) core.Sample {
+	if s.kind == core.Counter {
+		return core.NewCounter(now, s.class, nil, uint64(v))
+	}
+	return core.NewGauge(now, s.class, nil, v)
+}
+
+// readProtoStats reads a /proc/net/snmp like file, made of header and
+// values lines pairs:
+// Tcp: RtoAlgorithm RtoMin RtoMax MaxConn ActiveOpens ...
+// Tcp: 1 200 120000 -1 248 ...
+func readProtoStats(path string) (map[string]map[string]int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	res := make(map[string]map[string]int64)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		keys := strings.Fields(scanner.Text())
+		if !scanner.Scan() {
+			break
+		}
+		values := strings.Fields(scanner.Text())
+		if len(keys) == 0 || len(keys) != len(values) || keys[0] != values[0] {
+			return nil, fmt.Errorf("%s: malformed section %v", path, keys)
+		}
+
+		section := strings.TrimSuffix(keys[0], ":")
+		res[section] = make(map[string]int64)
+		for i := 1; i < len(keys); i++ {
+			if v, err := strconv.ParseInt(values[i], 10, 64); err == nil {
+				res[section][keys[i]] = v
+			}
+		}
+	}
+
+	return res, scanner.Err()
+}
+
+// readSockstat reads /proc/net/sockstat:
+// TCP: inuse 4 orphan 0 tw 3 alloc 4 mem 0
+func readSockstat(path string) (map[string]map[string]int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	res := make(map[string]map[string]int64)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 {
+			continue
+		}
+
+		section := strings.TrimSuffix(fields[0], ":")
+		res[section] = make(map[string]int64)
+		for i := 1; i+1 < len(fields); i += 2 {
+			if v, err := strconv.ParseInt(fields[i+1], 10, 64); err == nil {
+				res[section][fields[i]] = v
+			}
+		}
+	}
+
+	return res, scanner.Err()
+}
+
+// countTCPStates counts the sockets of a /proc/net/tcp file by state
+func countTCPStates(path string, states []uint64) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Scan() // header
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 {
+			continue
+		}
+
+		code, err := strconv.ParseUint(fields[3], 16, 8)
+		if err == nil && int(code) < len(states) {
+			states[code]++
+		}
+	}
+
+	return scanner.Err()
+}
@@ -0,0 +1,99 @@
+package collectors
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadProtoStats(t *testing.T) {
+	root, cleanup := fakeTree(t, map[string]string{
+		"snmp": "Ip: Forwarding DefaultTTL InReceives\n" +
+			"Ip: 1 64 12345\n" +
+			"Tcp: RtoAlgorithm MaxConn ActiveOpens CurrEstab\n" +
+			"Tcp: 1 -1 100 4\n",
+		"netstat": "TcpExt: SyncookiesSent ListenOverflows\n" +
+			"TcpExt: 0 7\n",
+		"truncated": "Ip: Forwarding DefaultTTL\n",
+		"mismatch":  "Ip: Forwarding DefaultTTL\nIp: 1\n",
+		"sections":  "Ip: Forwarding\nTcp: 1\n",
+	})
+	defer cleanup()
+
+	tests := []struct {
+		file string
+		want map[string]map[string]int64
+		err  bool
+	}{
+		{file: "snmp", want: map[string]map[string]int64{
+			"Ip":  {"Forwarding": 1, "DefaultTTL": 64, "InReceives": 12345},
+			"Tcp": {"RtoAlgorithm": 1, "MaxConn": -1, "ActiveOpens": 100, "CurrEstab": 4},
+		}},
+		{file: "netstat", want: map[string]map[string]int64{
+			"TcpExt": {"SyncookiesSent": 0, "ListenOverflows": 7},
+		}},
+		{file: "truncated", want: map[string]map[string]int64{}},
+		{file: "mismatch", err: true},
+		{file: "sections", err: true},
+		{file: "missing", err: true},
+	}
+
+	for _, tt := range tests {
+		got, err := readProtoStats(filepath.Join(root, tt.file))
+		if (err != nil) != tt.err {
+			t.Errorf("%s: error = %v, want error %v", tt.file, err, tt.err)
+			continue
+		}
+		if !tt.err && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.file, got, tt.want)
+		}
+	}
+}
+
+func TestReadSockstat(t *testing.T) {
+	root, cleanup := fakeTree(t, map[string]string{
+		"sockstat": "sockets: used 200\n" +
+			"TCP: inuse 4 orphan 0 tw 3 alloc 4 mem 1\n" +
+			"UDP: inuse 2 mem 1\n" +
+			"FRAG: inuse 0 memory 0\n" +
+			"broken\n",
+	})
+	defer cleanup()
+
+	got, err := readSockstat(filepath.Join(root, "sockstat"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]map[string]int64{
+		"sockets": {"used": 200},
+		"TCP":     {"inuse": 4, "orphan": 0, "tw": 3, "alloc": 4, "mem": 1},
+		"UDP":     {"inuse": 2, "mem": 1},
+		"FRAG":    {"inuse": 0, "memory": 0},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if _, err := readSockstat(filepath.Join(root, "missing")); err == nil {
+		t.Error("missing file read")
+	}
+}
+
+func TestCountTCPStates(t *testing.T) {
+	root, cleanup := fakeTree(t, map[string]string{
+		"tcp": "  sl  local_address rem_address   st tx_queue rx_queue\n" +
+			"   0: 00000000:0016 00000000:0000 0A 00000000:00000000\n" +
+			"   1: 0100007F:0277 00000000:0000 0A 00000000:00000000\n" +
+			"   2: 0A00020F:0016 0A000202:C5B8 01 00000000:00000000\n",
+	})
+	defer cleanup()
+
+	states := make([]uint64, 12)
+	if err := countTCPStates(filepath.Join(root, "tcp"), states); err != nil {
+		t.Fatal(err)
+	}
+	if states[0x01] != 1 || states[0x0A] != 2 {
+		t.Errorf("got states %v, want 1 established and 2 listen", states)
+	}
+}
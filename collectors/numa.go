@@ -0,0 +1,62 @@
+package collectors
+
+import (
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// numaNodes lists the NUMA node ids of /sys/devices/system/node, none
+// being returned on kernels built without NUMA support.
+func numaNodes() []string {
+	entries, err := ioutil.ReadDir(sysPath("devices", "system", "node"))
+	if err != nil {
+		return nil
+	}
+
+	var nodes []int
+	for _, e := range entries {
+		if !strings.HasPrefix(e.Name(), "node") {
+			continue
+		}
+		if id, err := strconv.Atoi(strings.TrimPrefix(e.Name(), "node")); err == nil {
+			nodes = append(nodes, id)
+		}
+	}
+	sort.Ints(nodes)
+
+	res := make([]string, len(nodes))
+	for i, id := range nodes {
+		res[i] = strconv.Itoa(id)
+	}
+	return res
+}
+
+// numaPath returns a path under a NUMA node directory
+func numaPath(node string, elems ...string) string {
+	return sysPath(append([]string{"devices", "system", "node", "node" + node}, elems...)...)
+}
+
+// cpuNodes maps the cpu names, as cpu0, to their NUMA node id
+func cpuNodes() map[string]string {
+	res := make(map[string]string)
+	for _, node := range numaNodes() {
+		entries, err := ioutil.ReadDir(numaPath(node))
+		if err != nil {
+			continue
+		}
+
+		for _, e := range entries {
+			name := e.Name()
+			if !strings.HasPrefix(name, "cpu") || e.Mode()&os.ModeSymlink == 0 {
+				continue // cpulist, cpumap...
+			}
+			if _, err := strconv.Atoi(strings.TrimPrefix(name, "cpu")); err == nil {
+				res[name] = node
+			}
+		}
+	}
+	return res
+}
@@ -0,0 +1,64 @@
+package collectors
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestNumaNodes(t *testing.T) {
+	root, cleanup := fakeTree(t, map[string]string{
+		"devices/system/node/node0/meminfo":  "",
+		"devices/system/node/node1/meminfo":  "",
+		"devices/system/node/node10/meminfo": "",
+		"devices/system/node/online":         "0-1,10\n",
+		"devices/system/node/possible":       "0-15\n",
+		"devices/system/cpu/cpu0/online":     "1\n",
+		"devices/system/cpu/cpu1/online":     "1\n",
+		"devices/system/cpu/cpu2/online":     "1\n",
+	})
+	defer cleanup()
+	defer setEnv("HOST_SYS", root)()
+
+	links := map[string]string{
+		"node0/cpu0": "../../cpu/cpu0",
+		"node0/cpu1": "../../cpu/cpu1",
+		"node1/cpu2": "../../cpu/cpu2",
+	}
+	for link, target := range links {
+		if err := os.Symlink(target, filepath.Join(root, "devices", "system", "node", link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// not a cpu link
+	if err := os.MkdirAll(filepath.Join(root, "devices", "system", "node", "node1", "cpumap"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := numaNodes(), []string{"0", "1", "10"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("numaNodes() = %v, want %v", got, want)
+	}
+
+	if got, want := numaPath("1", "meminfo"), filepath.Join(root, "devices", "system", "node", "node1", "meminfo"); got != want {
+		t.Errorf("numaPath() = %s, want %s", got, want)
+	}
+
+	want := map[string]string{"cpu0": "0", "cpu1": "0", "cpu2": "1"}
+	if got := cpuNodes(); !reflect.DeepEqual(got, want) {
+		t.Errorf("cpuNodes() = %v, want %v", got, want)
+	}
+}
+
+func TestNumaNodesWithoutNuma(t *testing.T) {
+	root, cleanup := fakeTree(t, nil)
+	defer cleanup()
+	defer setEnv("HOST_SYS", root)()
+
+	if got := numaNodes(); got != nil {
+		t.Errorf("numaNodes() = %v without NUMA support", got)
+	}
+	if got := cpuNodes(); len(got) != 0 {
+		t.Errorf("cpuNodes() = %v without NUMA support", got)
+	}
+}
@@ -0,0 +1,59 @@
+package collectors
+
+import (
+	"github.com/ovh/noderig/core"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cast"
+)
+
+// counterMode reads the counters option of a collector: counter, rate or both
+func counterMode(opts interface{}) core.RateMode {
+	options, ok := opts.(map[string]interface{})
+	if !ok {
+		return core.CountersOnly
+	}
+
+	val, ok := options["counters"].(string)
+	if !ok {
+		return core.CountersOnly
+	}
+
+	mode, err := core.ParseRateMode(val)
+	if err != nil {
+		log.Warn(err)
+	}
+	return mode
+}
+
+// hasOption tells whether a collector option is set
+func hasOption(opts interface{}, key string) bool {
+	options, ok := opts.(map[string]interface{})
+	return ok && options[key] != nil
+}
+
+// stringsOption reads a list of strings from collector options
+func stringsOption(opts interface{}, key string) []string {
+	options, ok := opts.(map[string]interface{})
+	if !ok || options[key] == nil {
+		return nil
+	}
+	return cast.ToStringSlice(options[key])
+}
+
+// stringOption reads a string from collector options
+func stringOption(opts interface{}, key string, def string) string {
+	options, ok := opts.(map[string]interface{})
+	if !ok || options[key] == nil {
+		return def
+	}
+	return cast.ToString(options[key])
+}
+
+// intOption reads an integer from collector options
+func intOption(opts interface{}, key string, def int) int {
+	options, ok := opts.(map[string]interface{})
+	if !ok || options[key] == nil {
+		return def
+	}
+	return cast.ToInt(options[key])
+}
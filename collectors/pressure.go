@@ -0,0 +1,166 @@
+package collectors
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ovh/noderig/core"
+	log "github.com/sirupsen/logrus"
+)
+
+func init() {
+	core.Describe("os.pressure.avg10", core.Percent, "Percentage of time tasks stalled on a resource over 10 seconds")
+	core.Describe("os.pressure.avg60", core.Percent, "Percentage of time tasks stalled on a resource over 60 seconds")
+	core.Describe("os.pressure.avg300", core.Percent, "Percentage of time tasks stalled on a resource over 300 seconds")
+	core.Describe("os.pressure.total.us", core.Microseconds, "Total time tasks stalled on a resource (us)")
+}
+
+// pressureResources are the resources exposed under /proc/pressure
+var pressureResources = []string{"cpu", "memory", "io"}
+
+// Pressure collects Pressure Stall Information (PSI) metrics
+type Pressure struct {
+	mutex   sync.RWMutex
+	samples []core.Sample
+	rate    *core.Rate
+	level   uint8
+}
+
+// NewPressure returns an initialized Pressure collector.
+func NewPressure(period uint, level uint8, opts interface{}) *Pressure {
+	c := &Pressure{
+		level: level,
+		rate:  core.NewRate(counterMode(opts)),
+	}
+
+	if level == 0 {
+		return c
+	}
+
+	if _, err := os.Stat(procPath("pressure")); err != nil {
+		log.Warn("Pressure stall information is not available (linux 4.20+ with CONFIG_PSI), pressure collector disabled")
+		return c
+	}
+
+	tick := time.NewTicker(time.Duration(period) * time.Millisecond)
+	go func() {
+		for range tick.C {
+			if err := c.scrape(); err != nil {
+				log.Error(err)
+			}
+		}
+	}()
+
+	return c
+}
+
+// Samples delivers metrics.
+func (c *Pressure) Samples() []core.Sample {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	return append([]core.Sample(nil), c.samples...)
+}
+
+// pressureLine is a some or full line of a pressure file, e.g.
+// some avg10=1.53 avg60=1.10 avg300=1.10 total=54028516
+type pressureLine struct {
+	kind   string
+	avg10  float64
+	avg60  float64
+	avg300 float64
+	total  uint64
+}
+
+func (c *Pressure) scrape() error {
+	stats := make(map[string][]pressureLine)
+	for _, resource := range pressureResources {
+		lines, err := readPressure(procPath("pressure", resource))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		stats[resource] = lines
+	}
+
+	// protect consistency
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.samples = nil
+
+	class := "os.pressure"
+	now := time.Now().UnixNano() / 1000
+
+	for _, resource := range pressureResources {
+		for _, l := range stats[resource] {
+			labels := core.Labels{"resource": resource, "type": l.kind}
+
+			c.samples = append(c.samples, core.NewGauge(now, class+".avg10", labels, l.avg10))
+
+			if c.level > 1 {
+				c.samples = append(c.samples,
+					core.NewGauge(now, class+".avg60", labels, l.avg60),
+					core.NewGauge(now, class+".avg300", labels, l.avg300))
+			}
+
+			if c.level > 2 {
+				c.samples = append(c.samples, core.NewCounter(now, class+".total.us", labels, l.total))
+			}
+		}
+	}
+
+	c.samples = c.rate.Apply(c.samples)
+
+	return nil
+}
+
+func readPressure(path string) ([]pressureLine, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var res []pressureLine
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		l := pressureLine{kind: fields[0]}
+		for _, field := range fields[1:] {
+			kv := strings.SplitN(field, "=", 2)
+			if len(kv) != 2 {
+				return nil, fmt.Errorf("%s: malformed field '%s'", path, field)
+			}
+
+			var err error
+			switch kv[0] {
+			case "avg10":
+				l.avg10, err = strconv.ParseFloat(kv[1], 64)
+			case "avg60":
+				l.avg60, err = strconv.ParseFloat(kv[1], 64)
+			case "avg300":
+				l.avg300, err = strconv.ParseFloat(kv[1], 64)
+			case "total":
+				l.total, err = strconv.ParseUint(kv[1], 10, 64)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", path, err)
+			}
+		}
+		res = append(res, l)
+	}
+
+	return res, scanner.Err()
+}
@@ -0,0 +1,41 @@
+package collectors
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadPressure(t *testing.T) {
+	root, cleanup := fakeTree(t, map[string]string{
+		"cpu": "some avg10=1.53 avg60=1.10 avg300=0.90 total=54028516\n",
+		"memory": "some avg10=0.00 avg60=0.00 avg300=0.00 total=1234\n" +
+			"full avg10=0.50 avg60=0.25 avg300=0.10 total=567\n",
+		"malformed": "some avg10\n",
+		"invalid":   "some avg10=abc\n",
+	})
+	defer cleanup()
+
+	tests := []struct {
+		file string
+		want []pressureLine
+		err  bool
+	}{
+		{file: "cpu", want: []pressureLine{{"some", 1.53, 1.10, 0.90, 54028516}}},
+		{file: "memory", want: []pressureLine{{"some", 0, 0, 0, 1234}, {"full", 0.50, 0.25, 0.10, 567}}},
+		{file: "malformed", err: true},
+		{file: "invalid", err: true},
+		{file: "missing", err: true},
+	}
+
+	for _, tt := range tests {
+		got, err := readPressure(filepath.Join(root, tt.file))
+		if (err != nil) != tt.err {
+			t.Errorf("%s: error = %v, want error %v", tt.file, err, tt.err)
+			continue
+		}
+		if !tt.err && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.file, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,349 @@
+package collectors
+
+import (
+	"io/ioutil"
+	"os/user"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ovh/noderig/core"
+	"github.com/shirou/gopsutil/process"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cast"
+)
+
+func init() {
+	core.Describe("os.proc.count", core.NoUnit, "Processes count of the group")
+	core.Describe("os.proc.cpu", core.Percent, "Percentage of cpu used by the group processes")
+	core.Describe("os.proc.mem.rss", core.Bytes, "Resident memory of the group processes (bytes)")
+	core.Describe("os.proc.fds", core.NoUnit, "Open file descriptors of the group processes")
+	core.Describe("os.proc.threads", core.NoUnit, "Threads of the group processes")
+	core.Describe("os.proc.io.bytes", core.Bytes, "Io count of the group processes (bytes)")
+}
+
+// procGroup selects processes, all its set matchers having to match
+type procGroup struct {
+	name    string
+	names   []string       // process names, ~ prefixed values being regular expressions
+	cmdline *regexp.Regexp // command line regular expression
+	uids    []int32        // effective users
+	pidfile string         // file holding the pid of the process
+}
+
+// Proc collects per process group metrics
+type Proc struct {
+	groups []procGroup
+
+	times   map[int32]float64 // cpu seconds of the matched processes at the previous scrape
+	ios     map[int32]procIO  // io counters of the matched processes at the previous scrape
+	io      []procIO          // io of the groups since the collector start
+	scraped time.Time
+
+	mutex   sync.RWMutex
+	samples []core.Sample
+	rate    *core.Rate
+	level   uint8
+}
+
+// NewProc returns an initialized Proc collector.
+func NewProc(period uint, level uint8, opts interface{}) *Proc {
+	c := &Proc{
+		groups: procGroups(opts),
+		rate:   core.NewRate(counterMode(opts)),
+		level:  level,
+	}
+
+	if level == 0 {
+		return c
+	}
+
+	if len(c.groups) == 0 {
+		log.Warn("No process group defined in proc-opts, proc collector disabled")
+		return c
+	}
+
+	tick := time.NewTicker(time.Duration(period) * time.Millisecond)
+	go func() {
+		for range tick.C {
+			if err := c.scrape(); err != nil {
+				log.Error(err)
+			}
+		}
+	}()
+
+	return c
+}
+
+// procGroups parses the groups option, a map of group name to matchers
+func procGroups(opts interface{}) []procGroup {
+	options, ok := opts.(map[string]interface{})
+	if !ok || options["groups"] == nil {
+		return nil
+	}
+
+	var groups []procGroup
+	for name, spec := range cast.ToStringMap(options["groups"]) {
+		matchers := cast.ToStringMap(spec)
+		g := procGroup{
+			name:    name,
+			names:   stringsOption(matchers, "name"),
+			pidfile: stringOption(matchers, "pidfile", ""),
+		}
+
+		if expr := stringOption(matchers, "cmdline", ""); expr != "" {
+			re, err := regexp.Compile(expr)
+			if err != nil {
+				log.WithError(err).Errorf("Invalid cmdline of process group %s, group ignored", name)
+				continue
+			}
+			g.cmdline = re
+		}
+
+		for _, u := range stringsOption(matchers, "user") {
+			uid, err := lookupUID(u)
+			if err != nil {
+				log.WithError(err).Warnf("Unknown user %s in process group %s", u, name)
+				continue
+			}
+			g.uids = append(g.uids, uid)
+		}
+
+		if g.names == nil && g.cmdline == nil && g.uids == nil && g.pidfile == "" {
+			log.Warnf("Process group %s has no matcher, group ignored", name)
+			continue
+		}
+		groups = append(groups, g)
+	}
+
+	sort.Slice(groups, func(i, j int) bool { return groups[i].name < groups[j].name })
+	return groups
+}
+
+// lookupUID resolves a user name or numeric uid
+func lookupUID(name string) (int32, error) {
+	if uid, err := strconv.ParseInt(name, 10, 32); err == nil {
+		return int32(uid), nil
+	}
+
+	u, err := user.Lookup(name)
+	if err != nil {
+		return 0, err
+	}
+	uid, err := strconv.ParseInt(u.Uid, 10, 32)
+	return int32(uid), err
+}
+
+// Samples delivers metrics.
+func (c *Proc) Samples() []core.Sample {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	return append([]core.Sample(nil), c.samples...)
+}
+
+// procIO are io byte counts
+type procIO struct {
+	read  uint64
+	write uint64
+}
+
+// procStats are the aggregated statistics of a process group
+type procStats struct {
+	count   uint64
+	cpu     float64
+	rss     uint64
+	fds     uint64
+	threads uint64
+	io      procIO // io since the previous scrape
+}
+
+func (c *Proc) scrape() error {
+	pids, err := process.Pids()
+	if err != nil {
+		return err
+	}
+
+	pidfiles := make(map[string]int32)
+	for _, g := range c.groups {
+		if g.pidfile != "" {
+			pidfiles[g.pidfile] = readPidfile(g.pidfile)
+		}
+	}
+
+	now := time.Now()
+	elapsed := now.Sub(c.scraped).Seconds()
+	times := make(map[int32]float64)
+	ios := make(map[int32]procIO)
+	stats := make([]procStats, len(c.groups))
+
+	for _, pid := range pids {
+		p, err := process.NewProcess(pid)
+		if err != nil {
+			continue // process exited
+		}
+
+		matched := false
+		for i, g := range c.groups {
+			if !g.match(p, pidfiles) {
+				continue
+			}
+
+			if !matched {
+				matched = true
+				if t, err := p.Times(); err == nil {
+					times[pid] = t.User + t.System
+				}
+				if c.level > 2 {
+					if io, err := p.IOCounters(); err == nil {
+						ios[pid] = procIO{read: io.ReadBytes, write: io.WriteBytes}
+					}
+				}
+			}
+			c.aggregate(&stats[i], p, times, ios, elapsed)
+		}
+	}
+
+	c.times = times
+	c.ios = ios
+	c.scraped = now
+
+	if c.io == nil {
+		c.io = make([]procIO, len(c.groups))
+	}
+	for i := range c.groups {
+		c.io[i].read += stats[i].io.read
+		c.io[i].write += stats[i].io.write
+	}
+
+	// protect consistency
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.samples = nil
+
+	class := "os.proc"
+	tick := now.UnixNano() / 1000
+
+	for i, g := range c.groups {
+		s := stats[i]
+		labels := core.Labels{"group": g.name}
+
+		c.samples = append(c.samples, core.NewGauge(tick, class+".count", labels, s.count))
+		if s.count == 0 {
+			continue
+		}
+
+		c.samples = append(c.samples,
+			core.NewGauge(tick, class+".cpu", labels, s.cpu),
+			core.NewGauge(tick, class+".mem.rss", labels, s.rss))
+
+		if c.level > 1 {
+			c.samples = append(c.samples,
+				core.NewGauge(tick, class+".fds", labels, s.fds),
+				core.NewGauge(tick, class+".threads", labels, s.threads))
+		}
+
+		if c.level > 2 {
+			c.samples = append(c.samples,
+				core.NewCounter(tick, class+".io.bytes", core.Labels{"group": g.name, "direction": "read"}, c.io[i].read),
+				core.NewCounter(tick, class+".io.bytes", core.Labels{"group": g.name, "direction": "write"}, c.io[i].write))
+		}
+	}
+
+	c.samples = c.rate.Apply(c.samples)
+
+	return nil
+}
+
+// aggregate adds a process to its group statistics, the cpu percentage and
+// the io being computed from the usage since the previous scrape. Counters
+// of exited processes are thus never subtracted from the group io.
+func (c *Proc) aggregate(s *procStats, p *process.Process, times map[int32]float64, ios map[int32]procIO, elapsed float64) {
+	s.count++
+
+	if prev, ok := c.times[p.Pid]; ok && elapsed > 0 {
+		if cur, ok := times[p.Pid]; ok && cur >= prev {
+			s.cpu += (cur - prev) / elapsed * 100
+		}
+	}
+
+	if mem, err := p.MemoryInfo(); err == nil {
+		s.rss += mem.RSS
+	}
+
+	if c.level > 1 {
+		if fds, err := p.NumFDs(); err == nil {
+			s.fds += uint64(fds)
+		}
+		if threads, err := p.NumThreads(); err == nil {
+			s.threads += uint64(threads)
+		}
+	}
+
+	if prev, ok := c.ios[p.Pid]; ok {
+		if cur, ok := ios[p.Pid]; ok {
+			if cur.read >= prev.read {
+				s.io.read += cur.read - prev.read
+			}
+			if cur.write >= prev.write {
+				s.io.write += cur.write - prev.write
+			}
+		}
+	}
+}
+
+// match tells whether a process belongs to the group
+func (g procGroup) match(p *process.Process, pidfiles map[string]int32) bool {
+	if g.pidfile != "" && pidfiles[g.pidfile] != p.Pid {
+		return false
+	}
+
+	if g.names != nil {
+		name, err := p.Name()
+		if err != nil || !stringInSlice(name, g.names) {
+			return false
+		}
+	}
+
+	if g.uids != nil {
+		uids, err := p.Uids()
+		if err != nil || len(uids) < 2 || !int32InSlice(uids[1], g.uids) {
+			return false
+		}
+	}
+
+	if g.cmdline != nil {
+		cmdline, err := p.Cmdline()
+		if err != nil || !g.cmdline.MatchString(cmdline) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// readPidfile returns the pid held by a file, 0 when unreadable
+func readPidfile(path string) int32 {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	pid, err := strconv.ParseInt(strings.TrimSpace(string(content)), 10, 32)
+	if err != nil {
+		return 0
+	}
+	return int32(pid)
+}
+
+func int32InSlice(v int32, list []int32) bool {
+	for _, i := range list {
+		if i == v {
+			return true
+		}
+	}
+	return false
+}
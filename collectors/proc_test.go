@@ -0,0 +1,55 @@
+package collectors
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/shirou/gopsutil/process"
+)
+
+func TestProcIODelta(t *testing.T) {
+	p, err := process.NewProcess(int32(os.Getpid()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		prev map[int32]procIO
+		cur  procIO
+		want procIO
+	}{
+		{"first scrape", nil, procIO{100, 100}, procIO{}},
+		{"increase", map[int32]procIO{p.Pid: {100, 100}}, procIO{150, 120}, procIO{50, 20}},
+		{"counter decrease", map[int32]procIO{p.Pid: {100, 100}}, procIO{150, 10}, procIO{50, 0}},
+	}
+
+	for _, tt := range tests {
+		c := &Proc{ios: tt.prev, level: 3}
+
+		var s procStats
+		c.aggregate(&s, p, nil, map[int32]procIO{p.Pid: tt.cur}, 0)
+		if s.io != tt.want {
+			t.Errorf("%s: got io %+v, want %+v", tt.name, s.io, tt.want)
+		}
+		if s.count != 1 {
+			t.Errorf("%s: count = %d, want 1", tt.name, s.count)
+		}
+	}
+}
+
+func TestReadPidfile(t *testing.T) {
+	root, cleanup := fakeTree(t, map[string]string{
+		"valid.pid":   "1234\n",
+		"invalid.pid": "abc\n",
+	})
+	defer cleanup()
+
+	tests := map[string]int32{"valid.pid": 1234, "invalid.pid": 0, "missing.pid": 0}
+	for file, want := range tests {
+		if got := readPidfile(filepath.Join(root, file)); got != want {
+			t.Errorf("readPidfile(%s) = %d, want %d", file, got, want)
+		}
+	}
+}
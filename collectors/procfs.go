@@ -0,0 +1,108 @@
+package collectors
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// procPath returns a path under the proc filesystem, HOST_PROC overriding
+// its mount point as for gopsutil.
+func procPath(elems ...string) string {
+	return hostPath("HOST_PROC", "/proc", elems...)
+}
+
+// sysPath returns a path under the sys filesystem, HOST_SYS overriding
+// its mount point as for gopsutil.
+func sysPath(elems ...string) string {
+	return hostPath("HOST_SYS", "/sys", elems...)
+}
+
+func hostPath(env string, def string, elems ...string) string {
+	root := os.Getenv(env)
+	if root == "" {
+		root = def
+	}
+	return filepath.Join(append([]string{root}, elems...)...)
+}
+
+// readFlatKeyed reads a "key value" per line file, as cpu.stat or
+// memory.events, ignoring the lines whose value is not an integer.
+func readFlatKeyed(path string) (map[string]uint64, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[string]uint64)
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		if v, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+			res[fields[0]] = v
+		}
+	}
+	return res, nil
+}
+
+// readUint reads a file holding a single integer. The ok result is false
+// for other values, as the "max" of unlimited cgroup resources.
+func readUint(path string) (v uint64, ok bool, err error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, false, err
+	}
+
+	v, err = strconv.ParseUint(strings.TrimSpace(string(content)), 10, 64)
+	return v, err == nil, nil
+}
+
+// sortedKeys returns the keys of a flat keyed file in a stable order
+func sortedKeys(m map[string]uint64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// readMeminfo reads a /proc/meminfo like file, values being converted to
+// bytes when expressed in kB:
+// MemAvailable:    5611844 kB
+// HugePages_Total:       0
+func readMeminfo(path string) (map[string]uint64, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[string]uint64)
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+
+		// the key is the first field ending with a colon, node files
+		// prefixing it with "Node <n>"
+		for i, field := range fields {
+			if !strings.HasSuffix(field, ":") || i+1 >= len(fields) {
+				continue
+			}
+
+			v, err := strconv.ParseUint(fields[i+1], 10, 64)
+			if err != nil {
+				break
+			}
+			if i+2 < len(fields) && fields[i+2] == "kB" {
+				v *= 1024
+			}
+			res[strings.TrimSuffix(field, ":")] = v
+			break
+		}
+	}
+	return res, nil
+}
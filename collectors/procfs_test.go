@@ -0,0 +1,102 @@
+package collectors
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadMeminfo(t *testing.T) {
+	root, cleanup := fakeTree(t, map[string]string{
+		"meminfo": "MemTotal:       16314280 kB\n" +
+			"MemAvailable:    5611844 kB\n" +
+			"Dirty:                12 kB\n" +
+			"HugePages_Total:       4\n" +
+			"Hugepagesize:       2048 kB\n" +
+			"Broken:         abc kB\n" +
+			"\n",
+		"node0/meminfo": "Node 0 MemTotal:       8157140 kB\n" +
+			"Node 0 MemFree:         612052 kB\n" +
+			"Node 0 HugePages_Free:      2\n",
+	})
+	defer cleanup()
+
+	tests := []struct {
+		file string
+		want map[string]uint64
+		err  bool
+	}{
+		{
+			file: "meminfo",
+			want: map[string]uint64{
+				"MemTotal":        16314280 * 1024,
+				"MemAvailable":    5611844 * 1024,
+				"Dirty":           12 * 1024,
+				"HugePages_Total": 4,
+				"Hugepagesize":    2048 * 1024,
+			},
+		},
+		{
+			file: "node0/meminfo",
+			want: map[string]uint64{
+				"MemTotal":       8157140 * 1024,
+				"MemFree":        612052 * 1024,
+				"HugePages_Free": 2,
+			},
+		},
+		{file: "missing", err: true},
+	}
+
+	for _, tt := range tests {
+		got, err := readMeminfo(filepath.Join(root, tt.file))
+		if (err != nil) != tt.err {
+			t.Errorf("%s: error = %v, want error %v", tt.file, err, tt.err)
+			continue
+		}
+		if !tt.err && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.file, got, tt.want)
+		}
+	}
+}
+
+func TestReadFlatKeyed(t *testing.T) {
+	root, cleanup := fakeTree(t, map[string]string{
+		"vmstat": "nr_free_pages 1234\npgfault 987654\nallocstall_normal 3\nbroken\nnegative -1\n",
+	})
+	defer cleanup()
+
+	got, err := readFlatKeyed(filepath.Join(root, "vmstat"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]uint64{"nr_free_pages": 1234, "pgfault": 987654, "allocstall_normal": 3}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestReadUint(t *testing.T) {
+	root, cleanup := fakeTree(t, map[string]string{
+		"value": "4096\n",
+		"max":   "max\n",
+	})
+	defer cleanup()
+
+	tests := []struct {
+		file string
+		want uint64
+		ok   bool
+		err  bool
+	}{
+		{file: "value", want: 4096, ok: true},
+		{file: "max"},
+		{file: "missing", err: true},
+	}
+
+	for _, tt := range tests {
+		v, ok, err := readUint(filepath.Join(root, tt.file))
+		if v != tt.want || ok != tt.ok || (err != nil) != tt.err {
+			t.Errorf("readUint(%s) = %d, %v, %v, want %d, %v, error %v", tt.file, v, ok, err, tt.want, tt.ok, tt.err)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package collectors
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/shirou/gopsutil/disk"
+)
+
+// statPool runs filesystem usage calls in a bounded pool of workers, each
+// call being abandoned after a timeout.
+//
+// A statfs on a hung mount (stale NFS or CIFS server) blocks its worker
+// until the server answers. Such a mount is skipped until this pending call
+// returns, so that it cannot take the other workers, and a replacement
+// worker is started, the hung one exiting once its call returns.
+type statPool struct {
+	timeout time.Duration
+	jobs    chan *statJob
+	stat    func(path string) (*disk.UsageStat, error)
+
+	mutex sync.Mutex
+	hung  map[string]bool
+}
+
+type statJob struct {
+	path string
+	res  chan statResult
+	done bool // guarded by the pool mutex
+	hung bool // guarded by the pool mutex, the worker being replaced
+}
+
+type statResult struct {
+	usage *disk.UsageStat
+	err   error
+}
+
+func newStatPool(workers int, timeout time.Duration) *statPool {
+	p := &statPool{
+		timeout: timeout,
+		jobs:    make(chan *statJob),
+		stat:    disk.Usage,
+		hung:    make(map[string]bool),
+	}
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+	return p
+}
+
+func (p *statPool) work() {
+	for job := range p.jobs {
+		usage, err := p.stat(job.path)
+		job.res <- statResult{usage: usage, err: err}
+
+		p.mutex.Lock()
+		job.done = true
+		replaced := job.hung
+		delete(p.hung, job.path)
+		p.mutex.Unlock()
+
+		if replaced {
+			return
+		}
+	}
+}
+
+// usage returns the usage of the filesystem mounted on path
+func (p *statPool) usage(path string) (*disk.UsageStat, error) {
+	p.mutex.Lock()
+	hung := p.hung[path]
+	p.mutex.Unlock()
+	if hung {
+		return nil, fmt.Errorf("stat %s: previous call still pending", path)
+	}
+
+	job := &statJob{path: path, res: make(chan statResult, 1)}
+	timer := time.NewTimer(p.timeout)
+	defer timer.Stop()
+
+	select {
+	case p.jobs <- job:
+	case <-timer.C:
+		return nil, fmt.Errorf("stat %s: no worker available", path)
+	}
+
+	select {
+	case r := <-job.res:
+		return r.usage, r.err
+	case <-timer.C:
+		p.mutex.Lock()
+		defer p.mutex.Unlock()
+		if job.done {
+			r := <-job.res
+			return r.usage, r.err
+		}
+		p.hung[path] = true
+		job.hung = true
+		go p.work()
+		return nil, fmt.Errorf("stat %s: timed out after %v", path, p.timeout)
+	}
+}
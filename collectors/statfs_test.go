@@ -0,0 +1,52 @@
+package collectors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shirou/gopsutil/disk"
+)
+
+func TestStatPoolReplacesHungWorkers(t *testing.T) {
+	release := make(chan struct{})
+	p := newStatPool(1, 50*time.Millisecond)
+	p.stat = func(path string) (*disk.UsageStat, error) {
+		if path == "/hung" {
+			<-release
+		}
+		return &disk.UsageStat{Path: path}, nil
+	}
+
+	if _, err := p.usage("/hung"); err == nil {
+		t.Fatal("hung mount call did not time out")
+	}
+	if _, err := p.usage("/hung"); err == nil {
+		t.Error("hung mount called again while its call is pending")
+	}
+
+	// the single worker is hung, its replacement serves the other mounts
+	for _, path := range []string{"/", "/srv"} {
+		if usage, err := p.usage(path); err != nil || usage.Path != path {
+			t.Errorf("usage(%s) = %v, %v", path, usage, err)
+		}
+	}
+
+	close(release)
+	deadline := time.Now().Add(time.Second)
+	for {
+		p.mutex.Lock()
+		hung := p.hung["/hung"]
+		p.mutex.Unlock()
+		if !hung {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("hung mount never released")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if usage, err := p.usage("/hung"); err != nil || usage.Path != "/hung" {
+		t.Errorf("usage(/hung) after release = %v, %v", usage, err)
+	}
+}
@@ -0,0 +1,228 @@
+package collectors
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/ovh/noderig/core"
+	log "github.com/sirupsen/logrus"
+)
+
+func init() {
+	core.Describe("os.systemd.failed", core.NoUnit, "Number of failed systemd units")
+	core.Describe("os.systemd.unit.failed", core.NoUnit, "Systemd unit failed status")
+	core.Describe("os.systemd.unit.state", core.NoUnit, "Systemd unit active state")
+	core.Describe("os.systemd.unit.substate", core.NoUnit, "Systemd unit sub state")
+	core.Describe("os.systemd.unit.restarts", core.NoUnit, "Systemd service automatic restarts count")
+}
+
+// systemdUnit is a unit status as listed by the systemd manager
+type systemdUnit struct {
+	Name        string
+	Description string
+	LoadState   string
+	ActiveState string
+	SubState    string
+	Followed    string
+	Path        dbus.ObjectPath
+	JobID       uint32
+	JobType     string
+	JobPath     dbus.ObjectPath
+}
+
+// systemdManager is the part of the systemd manager API used by the collector
+type systemdManager interface {
+	ListUnits(ctx context.Context) ([]systemdUnit, error)
+	Restarts(ctx context.Context, unit systemdUnit) (uint32, error)
+	Close() error
+}
+
+// Systemd collects systemd units states over D-Bus
+type Systemd struct {
+	units   []string
+	timeout time.Duration // deadline of the D-Bus calls of a scrape
+	dial    func() (systemdManager, error)
+	conn    systemdManager
+	warn    bool
+
+	mutex   sync.RWMutex
+	samples []core.Sample
+	rate    *core.Rate
+	level   uint8
+}
+
+// NewSystemd returns an initialized Systemd collector.
+func NewSystemd(period uint, level uint8, opts interface{}) *Systemd {
+	c := &Systemd{
+		units:   stringsOption(opts, "units"),
+		timeout: time.Duration(period) * time.Millisecond,
+		dial:    dialSystemd,
+		warn:    true,
+		rate:    core.NewRate(counterMode(opts)),
+		level:   level,
+	}
+	if c.units == nil {
+		c.units = []string{`~\.service$`}
+	}
+
+	if level == 0 {
+		return c
+	}
+
+	tick := time.NewTicker(time.Duration(period) * time.Millisecond)
+	go func() {
+		for range tick.C {
+			if err := c.scrape(); err != nil {
+				log.Error(err)
+			}
+		}
+	}()
+
+	return c
+}
+
+// Samples delivers metrics.
+func (c *Systemd) Samples() []core.Sample {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	return append([]core.Sample(nil), c.samples...)
+}
+
+func (c *Systemd) scrape() error {
+	// a stuck systemd must not block the scrapes
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	units, err := c.listUnits(ctx)
+	if err != nil {
+		// no system bus or no systemd, expose nothing until it is reachable
+		if c.warn {
+			log.WithError(err).Warn("Cannot query systemd, systemd metrics are unavailable")
+			c.warn = false
+		}
+
+		c.mutex.Lock()
+		c.samples = nil
+		c.mutex.Unlock()
+		return nil
+	}
+	c.warn = true
+
+	now := time.Now().UnixNano() / 1000
+	class := "os.systemd"
+
+	var failed uint64
+	var samples []core.Sample
+	for _, u := range units {
+		if u.ActiveState == "failed" {
+			failed++
+		}
+		if !stringInSlice(u.Name, c.units) {
+			continue
+		}
+
+		labels := core.Labels{"unit": u.Name}
+		samples = append(samples, core.NewGauge(now, class+".unit.failed", labels, u.ActiveState == "failed"))
+
+		if c.level > 1 {
+			samples = append(samples,
+				core.NewGauge(now, class+".unit.state", labels, u.ActiveState),
+				core.NewGauge(now, class+".unit.substate", labels, u.SubState))
+		}
+
+		if c.level > 2 && u.LoadState == "loaded" {
+			if restarts, err := c.conn.Restarts(ctx, u); err == nil {
+				samples = append(samples, core.NewCounter(now, class+".unit.restarts", labels, uint64(restarts)))
+			}
+		}
+	}
+	samples = append([]core.Sample{core.NewGauge(now, class+".failed", nil, failed)}, samples...)
+
+	// protect consistency
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.samples = c.rate.Apply(samples)
+
+	return nil
+}
+
+// listUnits lists the loaded units, connecting to systemd when needed
+func (c *Systemd) listUnits(ctx context.Context) ([]systemdUnit, error) {
+	if c.conn == nil {
+		conn, err := c.dial()
+		if err != nil {
+			return nil, err
+		}
+		c.conn = conn
+	}
+
+	units, err := c.conn.ListUnits(ctx)
+	if err != nil {
+		_ = c.conn.Close()
+		c.conn = nil
+		return nil, err
+	}
+	return units, nil
+}
+
+const (
+	systemdBusName   = "org.freedesktop.systemd1"
+	systemdPath      = "/org/freedesktop/systemd1"
+	systemdManagerIf = "org.freedesktop.systemd1.Manager"
+	systemdServiceIf = "org.freedesktop.systemd1.Service"
+)
+
+// dbusSystemd queries the systemd manager on the system bus
+type dbusSystemd struct {
+	conn *dbus.Conn
+}
+
+// dialSystemd connects to the system bus, DBUS_SYSTEM_BUS_ADDRESS
+// overriding its default address.
+func dialSystemd() (systemdManager, error) {
+	conn, err := dbus.SystemBusPrivate()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := conn.Auth(nil); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	if err := conn.Hello(); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	return &dbusSystemd{conn: conn}, nil
+}
+
+func (s *dbusSystemd) ListUnits(ctx context.Context) ([]systemdUnit, error) {
+	var units []systemdUnit
+	err := s.conn.Object(systemdBusName, systemdPath).CallWithContext(ctx, systemdManagerIf+".ListUnits", 0).Store(&units)
+	return units, err
+}
+
+// Restarts returns the automatic restarts count of a service, other units
+// having no restart counter.
+func (s *dbusSystemd) Restarts(ctx context.Context, unit systemdUnit) (uint32, error) {
+	var v dbus.Variant
+	err := s.conn.Object(systemdBusName, unit.Path).
+		CallWithContext(ctx, "org.freedesktop.DBus.Properties.Get", 0, systemdServiceIf, "NRestarts").
+		Store(&v)
+	if err != nil {
+		return 0, err
+	}
+
+	var restarts uint32
+	err = v.Store(&restarts)
+	return restarts, err
+}
+
+func (s *dbusSystemd) Close() error {
+	return s.conn.Close()
+}
@@ -0,0 +1,143 @@
+package collectors
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ovh/noderig/core"
+)
+
+// fakeSystemd is a systemd manager answering with fixed units
+type fakeSystemd struct {
+	units    []systemdUnit
+	restarts map[string]uint32
+	hang     bool // block until the deadline
+	closed   bool
+}
+
+func (s *fakeSystemd) ListUnits(ctx context.Context) ([]systemdUnit, error) {
+	if s.hang {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return s.units, nil
+}
+
+func (s *fakeSystemd) Restarts(ctx context.Context, unit systemdUnit) (uint32, error) {
+	restarts, ok := s.restarts[unit.Name]
+	if !ok {
+		return 0, errors.New("no NRestarts property")
+	}
+	return restarts, nil
+}
+
+func (s *fakeSystemd) Close() error {
+	s.closed = true
+	return nil
+}
+
+func TestSystemdScrape(t *testing.T) {
+	manager := &fakeSystemd{
+		units: []systemdUnit{
+			{Name: "nginx.service", LoadState: "loaded", ActiveState: "active", SubState: "running"},
+			{Name: "backup.service", LoadState: "loaded", ActiveState: "failed", SubState: "failed"},
+			{Name: "gone.service", LoadState: "not-found", ActiveState: "inactive", SubState: "dead"},
+			{Name: "home.mount", LoadState: "loaded", ActiveState: "failed", SubState: "failed"},
+		},
+		restarts: map[string]uint32{"nginx.service": 2, "backup.service": 0},
+	}
+
+	tests := []struct {
+		name  string
+		level uint8
+		units []string
+		want  map[string]interface{}
+	}{
+		{
+			name:  "level 1",
+			level: 1,
+			units: []string{`~\.service$`},
+			want: map[string]interface{}{
+				"os.systemd.failed{}":                         uint64(2),
+				"os.systemd.unit.failed{unit=nginx.service}":  false,
+				"os.systemd.unit.failed{unit=backup.service}": true,
+				"os.systemd.unit.failed{unit=gone.service}":   false,
+			},
+		},
+		{
+			name:  "level 3",
+			level: 3,
+			units: []string{"nginx.service", "gone.service"},
+			want: map[string]interface{}{
+				"os.systemd.failed{}":                          uint64(2),
+				"os.systemd.unit.failed{unit=nginx.service}":   false,
+				"os.systemd.unit.state{unit=nginx.service}":    "active",
+				"os.systemd.unit.substate{unit=nginx.service}": "running",
+				"os.systemd.unit.restarts{unit=nginx.service}": uint64(2),
+				"os.systemd.unit.failed{unit=gone.service}":    false,
+				"os.systemd.unit.state{unit=gone.service}":     "inactive",
+				"os.systemd.unit.substate{unit=gone.service}":  "dead",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		c := &Systemd{
+			units:   tt.units,
+			timeout: time.Second,
+			dial:    func() (systemdManager, error) { return manager, nil },
+			rate:    core.NewRate(core.CountersOnly),
+			level:   tt.level,
+		}
+		if err := c.scrape(); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		checkValues(t, tt.name, c.Samples(), tt.want)
+	}
+}
+
+func TestSystemdUnreachable(t *testing.T) {
+	manager := &fakeSystemd{units: []systemdUnit{{Name: "nginx.service", ActiveState: "active"}}}
+	dialErr := errors.New("no system bus")
+
+	c := &Systemd{
+		units:   []string{"nginx.service"},
+		timeout: 10 * time.Millisecond,
+		rate:    core.NewRate(core.CountersOnly),
+		level:   1,
+	}
+
+	steps := []struct {
+		name    string
+		dial    func() (systemdManager, error)
+		hang    bool
+		samples int
+	}{
+		{"no bus", func() (systemdManager, error) { return nil, dialErr }, false, 0},
+		{"bus reachable", func() (systemdManager, error) { return manager, nil }, false, 2},
+		{"systemd stuck", func() (systemdManager, error) { return manager, nil }, true, 0},
+		{"systemd back", func() (systemdManager, error) { return manager, nil }, false, 2},
+	}
+
+	for _, step := range steps {
+		c.dial = step.dial
+		manager.hang = step.hang
+		manager.closed = false
+
+		start := time.Now()
+		if err := c.scrape(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("%s: scrape took %v", step.name, elapsed)
+		}
+		if got := len(c.Samples()); got != step.samples {
+			t.Errorf("%s: got %d samples, want %d", step.name, got, step.samples)
+		}
+		if step.hang && (!manager.closed || c.conn != nil) {
+			t.Errorf("%s: connection kept after a failed call", step.name)
+		}
+	}
+}
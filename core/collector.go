@@ -1,97 +1,62 @@
 package core
 
 import (
-	"bytes"
-	"fmt"
-	"net/url"
 	"strings"
 )
 
 var (
 
-	// Format is the collector ouptut format for Noderig
+	// Format is the default collector ouptut format for Noderig
 	Format = "sensision"
 
 	// Separator pattern for each classnames
 	Separator = "."
 
 	// DefaultLabels add default labels
-	DefaultLabels = ""
+	DefaultLabels = Labels{}
 )
 
 // Collector interface
 type Collector interface {
-	Metrics() *bytes.Buffer
+	Samples() []Sample
 }
 
-// GetSeriesOutput series output format rendering
-func GetSeriesOutput(tick int64, class string, labels string, value interface{}) string {
-	return GetSeriesOutputAttributes(tick, class, labels, "", value)
-}
-
-// GetSeriesOutputAttributes series output format rendering with Attributes
-func GetSeriesOutputAttributes(tick int64, class string, labels string, attributes string, value interface{}) string {
-
-	if Separator != "." {
-		class = strings.Replace(class, ".", Separator, -1)
-	}
-
-	if DefaultLabels != "" {
-		labels = strings.Replace(labels, "{", "", 1)
-		labels = strings.TrimSpace(labels)
-
-		prefix := ","
-		if strings.HasPrefix(labels, "}") {
-			prefix = ""
+// Gather collects the samples of each collector and applies the global
+// series settings (default labels, relabeling rules, class separator) to them.
+func Gather(cs []Collector) []Sample {
+	var res []Sample
+	for _, c := range cs {
+		for _, s := range c.Samples() {
+			if s, ok := normalize(s); ok {
+				res = append(res, s)
+			}
 		}
-		labels = "{" + DefaultLabels + prefix + labels
-	}
-
-	switch Format {
-	case "sensision":
-		return toSensisionFormat(tick, class, labels, attributes, value)
-	case "prometheus":
-		return toPrometheusFormat(tick, class, labels, value)
-	default:
-		return toSensisionFormat(tick, class, labels, attributes, value)
 	}
+	return res
 }
 
-func toSensisionFormat(tick int64, class string, labels string, attributes string, value interface{}) string {
-	gtsValue := ""
-	switch v := value.(type) {
-	case string:
-		gtsValue += fmt.Sprintf("'%v'", url.PathEscape(v))
-	default:
-		gtsValue += fmt.Sprintf("%v", v)
+func normalize(s Sample) (Sample, bool) {
+	if s.Help == "" && s.Unit == NoUnit {
+		s.Unit, s.Help, _ = Description(s.Class)
 	}
 
-	if len(attributes) > 0 {
-		return fmt.Sprintf("%v// %v%v%v %v\n", tick, class, labels, attributes, gtsValue)
+	if len(DefaultLabels) > 0 {
+		labels := DefaultLabels.Copy()
+		for k, v := range s.Labels {
+			labels[k] = v
+		}
+		s.Labels = labels
 	}
-	return fmt.Sprintf("%v// %v%v %v\n", tick, class, labels, gtsValue)
-}
 
-func toPrometheusFormat(tick int64, class string, labels string, value interface{}) string {
-	gtsValue := ""
-	switch value.(type) {
-	case string:
-		return ""
-	default:
-		gtsValue += fmt.Sprintf("%v", value)
+	// rules match classes with their original separator
+	s, ok := Relabel(s, RelabelRules)
+	if !ok {
+		return s, false
 	}
-	return fmt.Sprintf("%v%v %v %v\n", class, labels, gtsValue, tick/1000)
-}
 
-// ToLabels ensure the correct output format for a key/value set for series labels
-func ToLabels(key string, value interface{}) string {
-
-	switch Format {
-	case "sensision":
-		return fmt.Sprintf("%v=%v", key, value)
-	case "prometheus":
-		return fmt.Sprintf("%v=\"%v\"", key, value)
-	default:
-		return fmt.Sprintf("%v=%v", key, value)
+	if Separator != "." {
+		s.Class = strings.Replace(s.Class, ".", Separator, -1)
 	}
+
+	return s, true
 }
@@ -0,0 +1,88 @@
+package core
+
+import (
+	"testing"
+)
+
+type staticCollector []Sample
+
+func (c staticCollector) Samples() []Sample {
+	return c
+}
+
+func TestGather(t *testing.T) {
+	defer func(labels Labels, separator string) {
+		DefaultLabels, Separator = labels, separator
+	}(DefaultLabels, Separator)
+
+	Describe("test.gather.described", Bytes, "Described class (bytes)")
+
+	tests := []struct {
+		name      string
+		labels    Labels
+		separator string
+		sample    Sample
+		want      Sample
+	}{
+		{
+			name:   "description",
+			sample: NewGauge(1, "test.gather.described", nil, 1),
+			want:   Sample{Class: "test.gather.described", Unit: Bytes, Help: "Described class (bytes)"},
+		},
+		{
+			name:   "collector help kept",
+			sample: Sample{Class: "test.gather.described", Help: "Own help"},
+			want:   Sample{Class: "test.gather.described", Help: "Own help"},
+		},
+		{
+			name:   "default labels",
+			labels: Labels{"host": "default", "dc": "gra"},
+			sample: NewGauge(1, "test.gather.other", Labels{"host": "own"}, 1),
+			want:   Sample{Class: "test.gather.other", Labels: Labels{"host": "own", "dc": "gra"}},
+		},
+		{
+			name:      "separator",
+			separator: "_",
+			sample:    NewGauge(1, "test.gather.other", nil, 1),
+			want:      Sample{Class: "test_gather_other"},
+		},
+	}
+
+	for _, tt := range tests {
+		DefaultLabels, Separator = tt.labels, "."
+		if tt.separator != "" {
+			Separator = tt.separator
+		}
+
+		samples := Gather([]Collector{staticCollector{tt.sample}})
+		if len(samples) != 1 {
+			t.Errorf("%s: gathered %d samples, want 1", tt.name, len(samples))
+			continue
+		}
+
+		got := samples[0]
+		if got.Class != tt.want.Class || got.Unit != tt.want.Unit || got.Help != tt.want.Help {
+			t.Errorf("%s: got %s %v %q, want %s %v %q", tt.name, got.Class, got.Unit, got.Help, tt.want.Class, tt.want.Unit, tt.want.Help)
+		}
+		if len(got.Labels) != len(tt.want.Labels) {
+			t.Errorf("%s: got labels %v, want %v", tt.name, got.Labels, tt.want.Labels)
+		}
+		for k, v := range tt.want.Labels {
+			if got.Labels[k] != v {
+				t.Errorf("%s: got labels %v, want %v", tt.name, got.Labels, tt.want.Labels)
+			}
+		}
+	}
+}
+
+func TestGatherDoesNotAlterCollectorLabels(t *testing.T) {
+	defer func(labels Labels) { DefaultLabels = labels }(DefaultLabels)
+	DefaultLabels = Labels{"host": "a"}
+
+	labels := Labels{"iface": "eth0"}
+	Gather([]Collector{staticCollector{NewGauge(1, "test.gather.other", labels, 1)}})
+
+	if len(labels) != 1 {
+		t.Errorf("collector labels altered: %v", labels)
+	}
+}
@@ -0,0 +1,35 @@
+package core
+
+import (
+	"io"
+	"sort"
+)
+
+// Encoder renders samples into an output format
+type Encoder interface {
+	Encode(w io.Writer, samples []Sample) error
+	ContentType() string
+}
+
+var encoders = make(map[string]Encoder)
+
+// RegisterEncoder makes an encoder available under the given format name
+func RegisterEncoder(name string, e Encoder) {
+	encoders[name] = e
+}
+
+// GetEncoder returns the encoder registered for the given format name
+func GetEncoder(name string) (Encoder, bool) {
+	e, ok := encoders[name]
+	return e, ok
+}
+
+// Formats returns the registered format names
+func Formats() []string {
+	res := make([]string, 0, len(encoders))
+	for name := range encoders {
+		res = append(res, name)
+	}
+	sort.Strings(res)
+	return res
+}
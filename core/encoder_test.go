@@ -0,0 +1,136 @@
+package core
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func encode(t *testing.T, format string, samples []Sample) string {
+	t.Helper()
+
+	e, ok := GetEncoder(format)
+	if !ok {
+		t.Fatalf("no %s encoder registered", format)
+	}
+
+	var b bytes.Buffer
+	if err := e.Encode(&b, samples); err != nil {
+		t.Fatalf("%s encoding failed: %v", format, err)
+	}
+	return b.String()
+}
+
+func TestEncoders(t *testing.T) {
+	mem := NewGauge(1500000, "os.mem", nil, 12.5)
+	mem.Unit, mem.Help = Percent, "Percentage of memory used"
+
+	read := NewCounter(1500000, "os.disk.fs.io.read.ms", Labels{"name": "sda"}, uint64(1500))
+	read.Unit, read.Help = Milliseconds, "Disk io read time (ms)"
+
+	readRate := NewGauge(1500000, "os.disk.fs.io.read.ms.rate", Labels{"name": "sda"}, 250.0)
+	readRate.Unit, readRate.Help = Milliseconds.Rate(), "Disk io read time (ms), per second rate"
+
+	state := NewGauge(1500000, "os.systemd.unit.state", Labels{"unit": "nginx.service"}, "active")
+	failed := NewGauge(1500000, "os.systemd.unit.failed", Labels{"unit": "nginx.service"}, false)
+
+	fs := NewGauge(1500000, "os.disk.fs", Labels{"disk": "/dev/sda1", "mount": "/"}, 42.0)
+	fs.Attributes = Labels{"fstype": "ext4"}
+
+	tests := []struct {
+		format  string
+		samples []Sample
+		want    string
+	}{
+		{"sensision", []Sample{mem}, "1500000// os.mem{} 12.5\n"},
+		{"sensision", []Sample{read}, "1500000// os.disk.fs.io.read.ms{name=sda} 1500\n"},
+		{"sensision", []Sample{state}, "1500000// os.systemd.unit.state{unit=nginx.service} 'active'\n"},
+		{"sensision", []Sample{fs}, "1500000// os.disk.fs{disk=/dev/sda1,mount=/}{fstype=ext4} 42\n"},
+		{"sensision", []Sample{NewGauge(1, "a b", Labels{"k": "x,y{z}=w"}, "a b")}, "1// a%20b{k=x%2Cy%7Bz%7D%3Dw} 'a%20b'\n"},
+
+		{"prometheus", []Sample{mem}, "# HELP os_mem Percentage of memory used\n# TYPE os_mem gauge\nos_mem 12.5 1500\n"},
+		{"prometheus", []Sample{read}, "# HELP os_disk_fs_io_read_ms Disk io read time (ms)\n# TYPE os_disk_fs_io_read_ms counter\n" +
+			"os_disk_fs_io_read_ms{name=\"sda\"} 1500 1500\n"},
+		{"prometheus", []Sample{state, failed}, "# HELP os_systemd_unit_state os.systemd.unit.state\n# TYPE os_systemd_unit_state gauge\n" +
+			"os_systemd_unit_state{unit=\"nginx.service\",value=\"active\"} 1 1500\n" +
+			"# HELP os_systemd_unit_failed os.systemd.unit.failed\n# TYPE os_systemd_unit_failed gauge\n" +
+			"os_systemd_unit_failed{unit=\"nginx.service\"} 0 1500\n"},
+		{"prometheus", []Sample{NewGauge(1000, "9.x-y", Labels{"__a": "q\"\\\n"}, 1)}, "# HELP _9_x_y 9.x-y\n# TYPE _9_x_y gauge\n" +
+			"_9_x_y{_a=\"q\\\"\\\\\\n\"} 1 1\n"},
+
+		{"openmetrics", []Sample{mem}, "# TYPE os_mem_ratio gauge\n# UNIT os_mem_ratio ratio\n# HELP os_mem_ratio Percentage of memory used\n" +
+			"os_mem_ratio 0.125 1.500000\n# EOF\n"},
+		{"openmetrics", []Sample{read}, "# TYPE os_disk_fs_io_read_seconds counter\n# UNIT os_disk_fs_io_read_seconds seconds\n" +
+			"# HELP os_disk_fs_io_read_seconds Disk io read time (ms)\n" +
+			"os_disk_fs_io_read_seconds_total{name=\"sda\"} 1.5 1.500000\n# EOF\n"},
+		{"openmetrics", []Sample{readRate}, "# TYPE os_disk_fs_io_read_ms_rate_seconds_per_second gauge\n" +
+			"# UNIT os_disk_fs_io_read_ms_rate_seconds_per_second seconds_per_second\n" +
+			"# HELP os_disk_fs_io_read_ms_rate_seconds_per_second Disk io read time (ms), per second rate\n" +
+			"os_disk_fs_io_read_ms_rate_seconds_per_second{name=\"sda\"} 0.25 1.500000\n# EOF\n"},
+		{"openmetrics", nil, "# EOF\n"},
+
+		{"influx", []Sample{
+			NewGauge(1000, "os.cpu.iowait", Labels{"chore": "0"}, 1.5),
+			NewGauge(1000, "os.cpu.user", Labels{"chore": "0"}, 2.0),
+			NewGauge(1000, "os.cpu.user", Labels{"chore": "1"}, 3.0),
+		}, "os.cpu,chore=0 iowait=1.5,user=2 1000000\nos.cpu,chore=1 user=3 1000000\n"},
+		{"influx", []Sample{
+			NewCounter(1, "os.net.bytes", Labels{"iface": "eth 0", "empty": ""}, uint64(10)),
+			NewGauge(1, "load", nil, int64(-2)),
+			state,
+			NewGauge(1, "os.mem", nil, math.NaN()),
+		}, "os.net,iface=eth\\ 0 bytes=10u 1000\nload value=-2i 1000\n" +
+			"os.systemd.unit,unit=nginx.service state=\"active\" 1500000000\n"},
+		{"influx", []Sample{fs}, "os.disk,disk=/dev/sda1,fstype=ext4,mount=/ fs=42 1500000000\n"},
+
+		{"json", []Sample{mem, NewGauge(1, "os.swap", nil, math.Inf(1))},
+			`[{"class":"os.mem","labels":{},"timestamp":1500000,"value":12.5,"kind":"gauge"},` +
+				`{"class":"os.swap","labels":{},"timestamp":1,"value":null,"kind":"gauge"}]` + "\n"},
+		{"json", nil, "[]\n"},
+		{"jsonl", []Sample{read, fs},
+			`{"class":"os.disk.fs.io.read.ms","labels":{"name":"sda"},"timestamp":1500000,"value":1500,"kind":"counter"}` + "\n" +
+				`{"class":"os.disk.fs","labels":{"disk":"/dev/sda1","mount":"/"},"attributes":{"fstype":"ext4"},"timestamp":1500000,"value":42,"kind":"gauge"}` + "\n"},
+	}
+
+	for _, tt := range tests {
+		if got := encode(t, tt.format, tt.samples); got != tt.want {
+			t.Errorf("%s encoding of %v:\ngot:\n%s\nwant:\n%s", tt.format, tt.samples, got, tt.want)
+		}
+	}
+}
+
+func TestOpenMetricsKeepsSeriesPointsContiguous(t *testing.T) {
+	samples := []Sample{
+		NewGauge(2000000, "os.load1", Labels{"host": "a"}, 1.0),
+		NewGauge(1000000, "os.load1", Labels{"host": "b"}, 2.0),
+		NewGauge(1000000, "os.load1", Labels{"host": "a"}, 3.0),
+	}
+
+	want := "# TYPE os_load1 gauge\n# HELP os_load1 os.load1\n" +
+		"os_load1{host=\"a\"} 3 1.000000\nos_load1{host=\"a\"} 1 2.000000\nos_load1{host=\"b\"} 2 1.000000\n# EOF\n"
+	if got := encode(t, "openmetrics", samples); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestContentTypes(t *testing.T) {
+	tests := map[string]string{
+		"sensision":   "text/plain; charset=utf-8",
+		"prometheus":  "text/plain; version=0.0.4; charset=utf-8",
+		"openmetrics": "application/openmetrics-text; version=1.0.0; charset=utf-8",
+		"influx":      "text/plain; charset=utf-8",
+		"json":        "application/json",
+		"jsonl":       "application/x-ndjson",
+	}
+
+	for format, want := range tests {
+		e, ok := GetEncoder(format)
+		if !ok {
+			t.Errorf("no %s encoder registered", format)
+			continue
+		}
+		if got := e.ContentType(); got != want {
+			t.Errorf("%s content type = %q, want %q", format, got, want)
+		}
+	}
+}
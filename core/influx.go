@@ -0,0 +1,126 @@
+package core
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	RegisterEncoder("influx", &InfluxEncoder{})
+}
+
+// InfluxEncoder renders samples in the InfluxDB line protocol.
+//
+// The last segment of a class is the field name, the others being the
+// measurement: os.cpu.iowait becomes the iowait field of the os.cpu
+// measurement. Labels and attributes become tags, and samples sharing a
+// measurement, tags and timestamp are merged into a single point.
+type InfluxEncoder struct{}
+
+// ContentType of the line protocol output
+func (e *InfluxEncoder) ContentType() string {
+	return "text/plain; charset=utf-8"
+}
+
+// Encode writes samples as line protocol points
+func (e *InfluxEncoder) Encode(w io.Writer, samples []Sample) error {
+	bw := bufio.NewWriter(w)
+	for _, line := range ToInflux(samples) {
+		bw.WriteString(line + "\n")
+	}
+
+	// bufio errors are sticky, the first write failure is returned by Flush
+	return bw.Flush()
+}
+
+type influxPoint struct {
+	series string
+	fields []string
+	tick   int64
+}
+
+// ToInflux renders samples as line protocol points
+func ToInflux(samples []Sample) []string {
+	var points []*influxPoint
+	index := make(map[string]*influxPoint)
+
+	for _, s := range samples {
+		if f, ok := s.Value.(float64); ok && (math.IsNaN(f) || math.IsInf(f, 0)) {
+			continue // not representable in line protocol
+		}
+
+		measurement, field := influxMeasurement(s.Class)
+
+		var series strings.Builder
+		series.WriteString(influxMeasurementReplacer.Replace(measurement))
+
+		tags := s.Labels
+		if len(s.Attributes) > 0 {
+			tags = s.Attributes.Copy()
+			for k, v := range s.Labels {
+				tags[k] = v
+			}
+		}
+		for _, k := range tags.Keys() {
+			if tags[k] == "" {
+				continue // empty tags are not allowed
+			}
+			series.WriteString("," + influxKeyReplacer.Replace(k) + "=" + influxKeyReplacer.Replace(tags[k]))
+		}
+
+		key := series.String() + " " + strconv.FormatInt(s.Timestamp, 10)
+		p, ok := index[key]
+		if !ok {
+			p = &influxPoint{series: series.String(), tick: s.Timestamp}
+			index[key] = p
+			points = append(points, p)
+		}
+		p.fields = append(p.fields, influxKeyReplacer.Replace(field)+"="+influxValue(s.Value))
+	}
+
+	res := make([]string, 0, len(points))
+	for _, p := range points {
+		// line protocol default precision is the nanosecond
+		res = append(res, p.series+" "+strings.Join(p.fields, ",")+" "+strconv.FormatInt(p.tick*1000, 10))
+	}
+	return res
+}
+
+// influxMeasurement splits a class into its measurement and field names
+func influxMeasurement(class string) (string, string) {
+	idx := strings.LastIndex(class, Separator)
+	if idx <= 0 || idx+len(Separator) >= len(class) {
+		return class, "value"
+	}
+	return class[:idx], class[idx+len(Separator):]
+}
+
+var (
+	influxMeasurementReplacer = strings.NewReplacer(",", `\,`, " ", `\ `, "\n", `\n`)
+	influxKeyReplacer         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `, "\n", `\n`)
+	influxStringReplacer      = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+)
+
+func influxValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return `"` + influxStringReplacer.Replace(v) + `"`
+	case bool:
+		return strconv.FormatBool(v)
+	case int:
+		return strconv.FormatInt(int64(v), 10) + "i"
+	case int32:
+		return strconv.FormatInt(int64(v), 10) + "i"
+	case int64:
+		return strconv.FormatInt(v, 10) + "i"
+	case uint32:
+		return strconv.FormatUint(uint64(v), 10) + "u"
+	case uint64:
+		return strconv.FormatUint(v, 10) + "u"
+	default:
+		return Sample{Value: v}.FormatValue()
+	}
+}
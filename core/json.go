@@ -0,0 +1,91 @@
+package core
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"math"
+)
+
+func init() {
+	RegisterEncoder("json", &JSONEncoder{})
+	RegisterEncoder("jsonl", &JSONEncoder{Lines: true})
+}
+
+// JSONEncoder renders samples as JSON objects, either in an array or as
+// JSON Lines (one object per line).
+type JSONEncoder struct {
+	Lines bool
+}
+
+// jsonSample is the JSON representation of a sample, timestamp being in
+// microseconds and non finite values being null.
+type jsonSample struct {
+	Class      string      `json:"class"`
+	Labels     Labels      `json:"labels"`
+	Attributes Labels      `json:"attributes,omitempty"`
+	Timestamp  int64       `json:"timestamp"`
+	Value      interface{} `json:"value"`
+	Kind       string      `json:"kind"`
+}
+
+// ContentType of the JSON output
+func (e *JSONEncoder) ContentType() string {
+	if e.Lines {
+		return "application/x-ndjson"
+	}
+	return "application/json"
+}
+
+// Encode writes samples as JSON
+func (e *JSONEncoder) Encode(w io.Writer, samples []Sample) error {
+	bw := bufio.NewWriter(w)
+
+	if !e.Lines {
+		bw.WriteString("[")
+	}
+	for i, s := range samples {
+		b, err := json.Marshal(toJSON(s))
+		if err != nil {
+			return err
+		}
+
+		if e.Lines {
+			bw.Write(b)
+			bw.WriteString("\n")
+			continue
+		}
+
+		if i > 0 {
+			bw.WriteString(",")
+		}
+		bw.Write(b)
+	}
+	if !e.Lines {
+		bw.WriteString("]\n")
+	}
+
+	// bufio errors are sticky, the first write failure is returned by Flush
+	return bw.Flush()
+}
+
+func toJSON(s Sample) jsonSample {
+	labels := s.Labels
+	if labels == nil {
+		labels = Labels{}
+	}
+
+	value := s.Value
+	if f, ok := value.(float64); ok && (math.IsNaN(f) || math.IsInf(f, 0)) {
+		value = nil
+	}
+
+	return jsonSample{
+		Class:      s.Class,
+		Labels:     labels,
+		Attributes: s.Attributes,
+		Timestamp:  s.Timestamp,
+		Value:      value,
+		Kind:       s.Kind.String(),
+	}
+}
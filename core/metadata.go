@@ -0,0 +1,76 @@
+package core
+
+import (
+	"sync"
+)
+
+// Unit of a series class
+type Unit struct {
+	// Name is the base unit exposed in OpenMetrics
+	Name string
+	// Scale converts collected values into the base unit, 0 meaning 1
+	Scale float64
+	// Suffix is a class suffix that already holds the collected unit
+	Suffix string
+	// PerSecond is set on the units of rates, Name ending with _per_second
+	PerSecond bool
+}
+
+// Rate returns the unit of the per second rate of values in u, rates of
+// unit-less counts staying unit-less.
+func (u Unit) Rate() Unit {
+	if u.Name == "" || u.PerSecond {
+		return u
+	}
+	u.Name += "_per_second"
+	u.PerSecond = true
+	return u
+}
+
+var (
+	// NoUnit is used for unit-less series (counts, loads)
+	NoUnit = Unit{}
+	// Bytes unit
+	Bytes = Unit{Name: "bytes"}
+	// Percent values are exposed as ratios
+	Percent = Unit{Name: "ratio", Scale: 0.01}
+	// Milliseconds values are exposed as seconds
+	Milliseconds = Unit{Name: "seconds", Scale: 0.001, Suffix: "ms"}
+	// Microseconds values are exposed as seconds
+	Microseconds = Unit{Name: "seconds", Scale: 0.000001, Suffix: "us"}
+	// Celsius unit
+	Celsius = Unit{Name: "celsius"}
+	// Hertz unit
+	Hertz = Unit{Name: "hertz"}
+	// Volts unit
+	Volts = Unit{Name: "volts"}
+	// Watts unit
+	Watts = Unit{Name: "watts"}
+)
+
+type metadata struct {
+	unit Unit
+	help string
+}
+
+var (
+	descriptions      = make(map[string]metadata)
+	descriptionsMutex sync.RWMutex
+)
+
+// Describe registers the unit and help text of a series class
+func Describe(class string, unit Unit, help string) {
+	descriptionsMutex.Lock()
+	defer descriptionsMutex.Unlock()
+
+	descriptions[class] = metadata{unit: unit, help: help}
+}
+
+// Description returns the unit and help text registered for a series class
+func Description(class string) (Unit, string, bool) {
+	descriptionsMutex.RLock()
+	defer descriptionsMutex.RUnlock()
+
+	m, ok := descriptions[class]
+	return m.unit, m.help, ok
+}
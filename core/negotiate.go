@@ -0,0 +1,149 @@
+package core
+
+import (
+	"fmt"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Negotiate selects the encoder for a request.
+//
+// An explicit format name takes precedence. Otherwise the Accept header is
+// matched against the encoders content types, and the encoder with the
+// highest quality among explicit media types is served. The default Format
+// wins ties, is served when only a wildcard matches, and is used when
+// nothing matches at all.
+func Negotiate(format string, accept string) (string, Encoder, error) {
+	if format != "" {
+		e, ok := GetEncoder(format)
+		if !ok {
+			return "", nil, fmt.Errorf("unknown format '%s', expected one of %s", format, strings.Join(Formats(), ", "))
+		}
+		return format, e, nil
+	}
+
+	def, ok := GetEncoder(Format)
+	if !ok {
+		return "", nil, fmt.Errorf("unknown default format '%s'", Format)
+	}
+
+	ranges := parseAccept(accept)
+	if len(ranges) == 0 {
+		return Format, def, nil
+	}
+
+	var (
+		best         string
+		bestEncoder  Encoder
+		bestQ        float64
+		bestExplicit bool
+	)
+	for _, name := range Formats() {
+		e, ok := GetEncoder(name)
+		if !ok || name == Format {
+			continue
+		}
+
+		q, explicit := quality(ranges, e)
+		if q <= 0 {
+			continue
+		}
+		if bestEncoder == nil || (explicit && !bestExplicit) || (explicit == bestExplicit && q > bestQ) {
+			best, bestEncoder, bestQ, bestExplicit = name, e, q, explicit
+		}
+	}
+
+	if q, _ := quality(ranges, def); q > 0 && (!bestExplicit || q >= bestQ) {
+		return Format, def, nil
+	}
+	if bestEncoder != nil {
+		return best, bestEncoder, nil
+	}
+	return Format, def, nil
+}
+
+// quality returns the quality of the most specific media range matching the
+// encoder content type, and whether that range names a media type rather
+// than the */* wildcard.
+func quality(ranges []mediaRange, e Encoder) (float64, bool) {
+	mediaType, params, err := mime.ParseMediaType(e.ContentType())
+	if err != nil {
+		return 0, false
+	}
+
+	best, q := -1, 0.0
+	for _, r := range ranges {
+		if !matchMediaType(r.mediaType, mediaType) {
+			continue
+		}
+		if v, ok := r.params["version"]; ok && params["version"] != v {
+			continue
+		}
+
+		if s := r.specificity(); s > best {
+			best, q = s, r.q
+		}
+	}
+	return q, best > 0
+}
+
+type mediaRange struct {
+	mediaType string
+	params    map[string]string
+	q         float64
+}
+
+func parseAccept(accept string) []mediaRange {
+	var res []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		mediaType, params, err := mime.ParseMediaType(part)
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+			delete(params, "q")
+		}
+		res = append(res, mediaRange{mediaType: mediaType, params: params, q: q})
+	}
+
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].q > res[j].q
+	})
+	return res
+}
+
+// specificity orders media ranges from */* to a type with parameters
+func (r mediaRange) specificity() int {
+	switch {
+	case r.mediaType == "*/*":
+		return 0
+	case strings.HasSuffix(r.mediaType, "/*"):
+		return 1
+	case len(r.params) == 0:
+		return 2
+	default:
+		return 3
+	}
+}
+
+func matchMediaType(pattern, mediaType string) bool {
+	if pattern == "*/*" || pattern == mediaType {
+		return true
+	}
+	if strings.HasSuffix(pattern, "/*") {
+		return strings.HasPrefix(mediaType, strings.TrimSuffix(pattern, "*"))
+	}
+	return false
+}
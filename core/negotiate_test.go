@@ -0,0 +1,87 @@
+package core
+
+import (
+	"testing"
+)
+
+// prometheusAccept is the Accept header sent by Prometheus scrapes
+const prometheusAccept = "application/openmetrics-text;version=1.0.0,application/openmetrics-text;version=0.0.1;q=0.75," +
+	"text/plain;version=0.0.4;q=0.5,*/*;q=0.1"
+
+func TestNegotiate(t *testing.T) {
+	defer func(format string) { Format = format }(Format)
+
+	tests := []struct {
+		def    string
+		format string
+		accept string
+		want   string
+	}{
+		{"sensision", "", "", "sensision"},
+		{"sensision", "prometheus", "application/json", "prometheus"},
+		{"sensision", "", "*/*", "sensision"},
+		{"sensision", "", "text/plain", "sensision"},
+		{"sensision", "", "text/plain;version=0.0.4", "prometheus"},
+		{"sensision", "", "application/json", "json"},
+		{"sensision", "", "application/x-ndjson, application/json;q=0.9", "jsonl"},
+		{"sensision", "", "image/png", "sensision"},
+		{"sensision", "", prometheusAccept, "openmetrics"},
+		{"sensision", "", "application/openmetrics-text, text/plain;version=0.0.4;q=0.5", "openmetrics"},
+
+		// the best explicit match wins, the default format on ties or wildcards
+		{"prometheus", "", prometheusAccept, "openmetrics"},
+		{"openmetrics", "", prometheusAccept, "openmetrics"},
+		{"json", "", "application/openmetrics-text, */*;q=0.1", "openmetrics"},
+		{"json", "", "*/*;q=0.1", "json"},
+		{"prometheus", "", "text/plain;version=0.0.4;q=0.5, application/openmetrics-text;q=0.5", "prometheus"},
+
+		// refused formats are skipped
+		{"json", "", "application/json;q=0, application/openmetrics-text, */*;q=0.1", "openmetrics"},
+		{"prometheus", "", "text/plain;version=0.0.4;q=0, application/json;q=0.2", "json"},
+		{"json", "", "application/*;q=0", "json"},
+	}
+
+	for _, tt := range tests {
+		Format = tt.def
+		got, _, err := Negotiate(tt.format, tt.accept)
+		if err != nil {
+			t.Errorf("Negotiate(%q, %q) with default %s failed: %v", tt.format, tt.accept, tt.def, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Negotiate(%q, %q) with default %s = %s, want %s", tt.format, tt.accept, tt.def, got, tt.want)
+		}
+	}
+}
+
+func TestNegotiateUnknownFormat(t *testing.T) {
+	if _, _, err := Negotiate("xml", ""); err == nil {
+		t.Error("unknown format accepted")
+	}
+
+	defer func(format string) { Format = format }(Format)
+	Format = "xml"
+	if _, _, err := Negotiate("", "*/*"); err == nil {
+		t.Error("unknown default format accepted")
+	}
+}
+
+func TestParseAccept(t *testing.T) {
+	ranges := parseAccept("text/plain;q=0.5, application/json;version=1, , bogus;;, */*;q=0")
+	if len(ranges) != 3 {
+		t.Fatalf("parsed %d ranges, want 3: %v", len(ranges), ranges)
+	}
+
+	if ranges[0].mediaType != "application/json" || ranges[0].q != 1 || ranges[0].params["version"] != "1" {
+		t.Errorf("first range = %+v, want application/json;version=1", ranges[0])
+	}
+	if ranges[1].mediaType != "text/plain" || ranges[1].q != 0.5 {
+		t.Errorf("second range = %+v, want text/plain;q=0.5", ranges[1])
+	}
+	if _, ok := ranges[1].params["q"]; ok {
+		t.Error("quality kept in media range parameters")
+	}
+	if ranges[2].mediaType != "*/*" || ranges[2].q != 0 {
+		t.Errorf("third range = %+v, want */*;q=0", ranges[2])
+	}
+}
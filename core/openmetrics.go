@@ -0,0 +1,134 @@
+package core
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	RegisterEncoder("openmetrics", &OpenMetricsEncoder{})
+}
+
+// OpenMetricsEncoder renders samples in the OpenMetrics text format (1.0.0).
+//
+// Family names carry their unit suffix, counters samples the _total suffix,
+// and values are converted into the base unit of their class. No exemplars
+// are emitted and the exposition is terminated by the EOF marker.
+type OpenMetricsEncoder struct{}
+
+// ContentType of the OpenMetrics output
+func (e *OpenMetricsEncoder) ContentType() string {
+	return "application/openmetrics-text; version=1.0.0; charset=utf-8"
+}
+
+// Encode writes samples as OpenMetrics metric families
+func (e *OpenMetricsEncoder) Encode(w io.Writer, samples []Sample) error {
+	bw := bufio.NewWriter(w)
+	for _, f := range groupFamilies(samples, openMetricsFamilyName) {
+		first := f.samples[0]
+
+		help := first.Help
+		if help == "" {
+			help = first.Class
+		}
+
+		bw.WriteString("# TYPE " + f.name + " " + first.Kind.String() + "\n")
+		if first.Unit.Name != "" {
+			bw.WriteString("# UNIT " + f.name + " " + first.Unit.Name + "\n")
+		}
+		bw.WriteString("# HELP " + f.name + " " + labelValueReplacer.Replace(help) + "\n")
+
+		name := f.name
+		if first.Kind == Counter {
+			name += "_total"
+		}
+
+		for _, s := range sortMetricPoints(f.samples) {
+			labels, value := openMetricsValue(s)
+
+			bw.WriteString(name)
+			bw.WriteString(prometheusLabels(labels))
+			bw.WriteString(" " + value + " ")
+			bw.WriteString(openMetricsTimestamp(s.Timestamp) + "\n")
+		}
+	}
+	bw.WriteString("# EOF\n")
+
+	// bufio errors are sticky, the first write failure is returned by Flush
+	return bw.Flush()
+}
+
+// openMetricsFamilyName returns the family name of a sample: counters lose
+// their _total suffix and the unit suffix is added when missing.
+func openMetricsFamilyName(s Sample) string {
+	name := strings.TrimSuffix(PrometheusName(s.Class), "_total")
+	return withUnitSuffix(name, s.Unit)
+}
+
+func withUnitSuffix(name string, unit Unit) string {
+	if unit.Name == "" {
+		return name
+	}
+	if unit.Suffix != "" {
+		name = strings.TrimSuffix(name, "_"+unit.Suffix)
+	}
+	if !strings.HasSuffix(name, "_"+unit.Name) {
+		name += "_" + unit.Name
+	}
+	return name
+}
+
+func openMetricsValue(s Sample) (Labels, string) {
+	if s.Unit.Scale == 0 || s.Unit.Scale == 1 {
+		return prometheusValue(s)
+	}
+
+	f, ok := s.Float()
+	if !ok {
+		return prometheusValue(s)
+	}
+	return s.Labels, strconv.FormatFloat(f*s.Unit.Scale, 'g', -1, 64)
+}
+
+// openMetricsTimestamp renders a microseconds timestamp in seconds
+func openMetricsTimestamp(tick int64) string {
+	return fmt.Sprintf("%d.%06d", tick/1000000, tick%1000000)
+}
+
+// sortMetricPoints keeps the points of a same series contiguous and in
+// increasing timestamp order, as required by OpenMetrics.
+func sortMetricPoints(samples []Sample) []Sample {
+	order := make(map[string]int)
+	keys := make([]string, len(samples))
+	for i, s := range samples {
+		keys[i] = prometheusLabels(s.Labels)
+		if _, ok := order[keys[i]]; !ok {
+			order[keys[i]] = len(order)
+		}
+	}
+	if len(order) == len(samples) {
+		return samples
+	}
+
+	idx := make([]int, len(samples))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(i, j int) bool {
+		oi, oj := order[keys[idx[i]]], order[keys[idx[j]]]
+		if oi != oj {
+			return oi < oj
+		}
+		return samples[idx[i]].Timestamp < samples[idx[j]].Timestamp
+	})
+
+	res := make([]Sample, len(samples))
+	for i, j := range idx {
+		res[i] = samples[j]
+	}
+	return res
+}
@@ -0,0 +1,155 @@
+package core
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	RegisterEncoder("prometheus", &PrometheusEncoder{})
+}
+
+// PrometheusEncoder renders samples in the Prometheus text exposition format
+// (version 0.0.4).
+//
+// Series are grouped by metric name, each family being introduced by its
+// HELP and TYPE lines. String values are exposed as a series of value 1
+// holding the string in a "value" label.
+type PrometheusEncoder struct{}
+
+// ContentType of the Prometheus output
+func (e *PrometheusEncoder) ContentType() string {
+	return "text/plain; version=0.0.4; charset=utf-8"
+}
+
+// Encode writes samples as Prometheus metric families
+func (e *PrometheusEncoder) Encode(w io.Writer, samples []Sample) error {
+	bw := bufio.NewWriter(w)
+	for _, f := range groupFamilies(samples, prometheusFamilyName) {
+		help := f.samples[0].Help
+		if help == "" {
+			help = f.samples[0].Class
+		}
+
+		bw.WriteString("# HELP " + f.name + " " + escapeHelp(help) + "\n")
+		bw.WriteString("# TYPE " + f.name + " " + f.samples[0].Kind.String() + "\n")
+
+		for _, s := range f.samples {
+			labels, value := prometheusValue(s)
+
+			bw.WriteString(f.name)
+			bw.WriteString(prometheusLabels(labels))
+			bw.WriteString(" " + value + " ")
+			bw.WriteString(strconv.FormatInt(s.Timestamp/1000, 10) + "\n")
+		}
+	}
+
+	// bufio errors are sticky, the first write failure is returned by Flush
+	return bw.Flush()
+}
+
+type family struct {
+	name    string
+	samples []Sample
+}
+
+// groupFamilies gathers samples sharing a metric name, in order of first appearance
+func groupFamilies(samples []Sample, name func(Sample) string) []*family {
+	var res []*family
+	index := make(map[string]*family)
+	for _, s := range samples {
+		n := name(s)
+		f, ok := index[n]
+		if !ok {
+			f = &family{name: n}
+			index[n] = f
+			res = append(res, f)
+		}
+		f.samples = append(f.samples, s)
+	}
+	return res
+}
+
+func prometheusFamilyName(s Sample) string {
+	return PrometheusName(s.Class)
+}
+
+func prometheusValue(s Sample) (Labels, string) {
+	switch v := s.Value.(type) {
+	case string:
+		labels := s.Labels.Copy()
+		labels["value"] = v
+		return labels, "1"
+	case bool:
+		if v {
+			return s.Labels, "1"
+		}
+		return s.Labels, "0"
+	default:
+		return s.Labels, s.FormatValue()
+	}
+}
+
+func prometheusLabels(labels Labels) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("{")
+	for i, k := range labels.Keys() {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		b.WriteString(PrometheusLabelName(k))
+		b.WriteString("=\"")
+		b.WriteString(labelValueReplacer.Replace(labels[k]))
+		b.WriteString("\"")
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+var (
+	labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpReplacer       = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeHelp(help string) string {
+	return helpReplacer.Replace(help)
+}
+
+// PrometheusName sanitizes a class into a valid Prometheus metric name
+func PrometheusName(class string) string {
+	return sanitizeName(class, true)
+}
+
+// PrometheusLabelName sanitizes a label key into a valid Prometheus label name
+func PrometheusLabelName(key string) string {
+	name := sanitizeName(key, false)
+	if strings.HasPrefix(name, "__") {
+		name = "_" + strings.TrimLeft(name, "_")
+	}
+	return name
+}
+
+// sanitizeName replaces each invalid character by an underscore. Colons are
+// only allowed in metric names.
+func sanitizeName(name string, colon bool) string {
+	b := []byte(name)
+	for i, c := range b {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '_':
+		case c == ':' && colon:
+		default:
+			b[i] = '_'
+		}
+	}
+
+	if len(b) == 0 || (b[0] >= '0' && b[0] <= '9') {
+		return "_" + string(b)
+	}
+	return string(b)
+}
@@ -0,0 +1,148 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// RateMode tells how a collector exposes its cumulative counters
+type RateMode uint8
+
+const (
+	// CountersOnly exposes the raw counters
+	CountersOnly RateMode = iota
+	// RatesOnly replaces the counters by their per second rate
+	RatesOnly
+	// CountersAndRates exposes both the counters and their rate
+	CountersAndRates
+)
+
+// ParseRateMode parses a rate mode: counter, rate or both
+func ParseRateMode(mode string) (RateMode, error) {
+	switch mode {
+	case "", "counter":
+		return CountersOnly, nil
+	case "rate":
+		return RatesOnly, nil
+	case "both":
+		return CountersAndRates, nil
+	default:
+		return CountersOnly, fmt.Errorf("unknown counters mode '%s', expected counter, rate or both", mode)
+	}
+}
+
+// RateSuffix is appended to the class of a counter to name its rate
+const RateSuffix = ".rate"
+
+// Rate computes the per second rate of counters between two scrapes.
+//
+// The previous sample of each series is kept until the series disappears.
+// A counter going backwards has been reset and no rate is emitted until the
+// next scrape, except for 32 bits counters (uint32 values) which wrap.
+type Rate struct {
+	mode     RateMode
+	previous map[string]Sample
+}
+
+// NewRate returns a rate engine for the given mode.
+func NewRate(mode RateMode) *Rate {
+	return &Rate{
+		mode:     mode,
+		previous: make(map[string]Sample),
+	}
+}
+
+// Apply returns the samples with their counters kept, replaced by or
+// followed by their rate, according to the mode. Gauges are left untouched.
+func (r *Rate) Apply(samples []Sample) []Sample {
+	if r.mode == CountersOnly {
+		return samples
+	}
+
+	res := make([]Sample, 0, len(samples))
+	previous := make(map[string]Sample, len(r.previous))
+	for _, s := range samples {
+		if s.Kind != Counter {
+			res = append(res, s)
+			continue
+		}
+		if r.mode == CountersAndRates {
+			res = append(res, s)
+		}
+
+		key := seriesKey(s)
+		prev, seen := r.previous[key]
+		previous[key] = s
+		if !seen {
+			continue
+		}
+
+		if rate, ok := counterRate(prev, s); ok {
+			res = append(res, rateSample(s, rate))
+		}
+	}
+	r.previous = previous
+
+	return res
+}
+
+// seriesKey identifies a series by its class and labels
+func seriesKey(s Sample) string {
+	var b strings.Builder
+	b.WriteString(s.Class)
+	for _, k := range s.Labels.Keys() {
+		b.WriteString("\x00" + k + "\x00" + s.Labels[k])
+	}
+	return b.String()
+}
+
+// counterRate returns the per second increase between two samples of a counter
+func counterRate(prev, cur Sample) (float64, bool) {
+	elapsed := float64(cur.Timestamp-prev.Timestamp) / 1000000
+	if elapsed <= 0 {
+		return 0, false
+	}
+
+	delta, ok := counterDelta(prev.Value, cur.Value)
+	if !ok {
+		return 0, false
+	}
+	return delta / elapsed, true
+}
+
+func counterDelta(prev, cur interface{}) (float64, bool) {
+	if p, ok := prev.(uint64); ok {
+		if c, ok := cur.(uint64); ok {
+			if c < p {
+				return 0, false // reset
+			}
+			return float64(c - p), true
+		}
+	}
+	if p, ok := prev.(uint32); ok {
+		if c, ok := cur.(uint32); ok {
+			return float64(c - p), true // wraps
+		}
+	}
+
+	pf, pok := Sample{Value: prev}.Float()
+	cf, cok := Sample{Value: cur}.Float()
+	if !pok || !cok || cf < pf || math.IsNaN(cf-pf) {
+		return 0, false
+	}
+	return cf - pf, true
+}
+
+// rateSample builds the rate gauge of a counter sample
+func rateSample(s Sample, rate float64) Sample {
+	res := NewGauge(s.Timestamp, s.Class+RateSuffix, s.Labels, rate)
+	res.Attributes = s.Attributes
+	if unit, help, ok := Description(s.Class); ok {
+		res.Unit = unit.Rate()
+		if help != "" {
+			res.Help = help + ", per second rate"
+		}
+	}
+	return res
+}
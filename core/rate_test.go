@@ -0,0 +1,150 @@
+package core
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseRateMode(t *testing.T) {
+	tests := map[string]RateMode{"": CountersOnly, "counter": CountersOnly, "rate": RatesOnly, "both": CountersAndRates}
+	for mode, want := range tests {
+		if got, err := ParseRateMode(mode); err != nil || got != want {
+			t.Errorf("ParseRateMode(%q) = %v, %v, want %v", mode, got, err, want)
+		}
+	}
+	if _, err := ParseRateMode("rates"); err == nil {
+		t.Error("unknown mode accepted")
+	}
+}
+
+func TestCounterDelta(t *testing.T) {
+	tests := []struct {
+		name      string
+		prev, cur interface{}
+		want      float64
+		ok        bool
+	}{
+		{"increase", uint64(10), uint64(25), 15, true},
+		{"steady", uint64(10), uint64(10), 0, true},
+		{"reset", uint64(1000), uint64(10), 0, false},
+		{"reset near 32 bits", uint64(math.MaxUint32 - 5), uint64(10), 0, false},
+		{"reset near 64 bits", uint64(math.MaxUint64 - 5), uint64(10), 0, false},
+		{"32 bits increase", uint32(10), uint32(25), 15, true},
+		{"32 bits wrap", uint32(math.MaxUint32 - 5), uint32(10), 16, true},
+		{"float increase", 1.5, 4.0, 2.5, true},
+		{"float reset", 4.0, 1.5, 0, false},
+		{"int increase", int64(3), int64(7), 4, true},
+		{"string", "a", "b", 0, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := counterDelta(tt.prev, tt.cur)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("%s: counterDelta(%v, %v) = %v, %v, want %v, %v", tt.name, tt.prev, tt.cur, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestRateApply(t *testing.T) {
+	Describe("test.rate.bytes", Bytes, "Test bytes")
+
+	load := NewGauge(1000000, "test.rate.load", nil, 1.0)
+	scrapes := []struct {
+		name    string
+		samples []Sample
+		want    map[string]float64 // expected rates by label value
+	}{
+		{
+			"first scrape",
+			[]Sample{load, NewCounter(1000000, "test.rate.bytes", Labels{"n": "a"}, uint64(100))},
+			map[string]float64{},
+		},
+		{
+			"increase",
+			[]Sample{
+				load,
+				NewCounter(3000000, "test.rate.bytes", Labels{"n": "a"}, uint64(300)),
+				NewCounter(3000000, "test.rate.bytes", Labels{"n": "b"}, uint64(10)),
+			},
+			map[string]float64{"a": 100},
+		},
+		{
+			"reset",
+			[]Sample{
+				NewCounter(4000000, "test.rate.bytes", Labels{"n": "a"}, uint64(50)),
+				NewCounter(4000000, "test.rate.bytes", Labels{"n": "b"}, uint64(20)),
+			},
+			map[string]float64{"b": 10},
+		},
+		{
+			"after reset",
+			[]Sample{NewCounter(5000000, "test.rate.bytes", Labels{"n": "a"}, uint64(60))},
+			map[string]float64{"a": 10},
+		},
+		{
+			"vanished series forgotten",
+			[]Sample{NewCounter(6000000, "test.rate.bytes", Labels{"n": "b"}, uint64(40))},
+			map[string]float64{},
+		},
+		{
+			"same timestamp",
+			[]Sample{NewCounter(6000000, "test.rate.bytes", Labels{"n": "b"}, uint64(50))},
+			map[string]float64{},
+		},
+	}
+
+	for _, mode := range []RateMode{RatesOnly, CountersAndRates} {
+		r := NewRate(mode)
+		for _, sc := range scrapes {
+			counters, gauges := 0, 0
+			rates := make(map[string]float64)
+			for _, s := range r.Apply(sc.samples) {
+				switch {
+				case s.Kind == Counter:
+					counters++
+				case s.Class == "test.rate.bytes"+RateSuffix:
+					rates[s.Labels["n"]], _ = s.Float()
+					if s.Help != "Test bytes, per second rate" {
+						t.Errorf("%s: rate help = %q", sc.name, s.Help)
+					}
+					if want := (Unit{Name: "bytes_per_second", PerSecond: true}); s.Unit != want {
+						t.Errorf("%s: rate unit = %+v, want %+v", sc.name, s.Unit, want)
+					}
+				default:
+					gauges++
+				}
+			}
+
+			wantCounters, wantGauges := 0, 0
+			for _, s := range sc.samples {
+				if s.Kind == Counter {
+					wantCounters++
+				} else {
+					wantGauges++
+				}
+			}
+			if mode == RatesOnly {
+				wantCounters = 0
+			}
+
+			if counters != wantCounters || gauges != wantGauges {
+				t.Errorf("mode %v, %s: got %d counters and %d gauges, want %d and %d", mode, sc.name, counters, gauges, wantCounters, wantGauges)
+			}
+			if len(rates) != len(sc.want) {
+				t.Errorf("mode %v, %s: got rates %v, want %v", mode, sc.name, rates, sc.want)
+			}
+			for k, v := range sc.want {
+				if rates[k] != v {
+					t.Errorf("mode %v, %s: got rates %v, want %v", mode, sc.name, rates, sc.want)
+				}
+			}
+		}
+	}
+}
+
+func TestRateCountersOnly(t *testing.T) {
+	samples := []Sample{NewCounter(1, "test.rate.bytes", nil, uint64(1))}
+	if got := NewRate(CountersOnly).Apply(samples); len(got) != 1 || got[0].Kind != Counter {
+		t.Errorf("counters only mode altered the samples: %v", got)
+	}
+}
@@ -0,0 +1,166 @@
+package core
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ClassLabel is the pseudo label holding the class of a sample in relabeling rules
+const ClassLabel = "__class__"
+
+// RelabelConfig is a relabeling rule, as in Prometheus metric_relabel_configs
+type RelabelConfig struct {
+	SourceLabels []string // labels whose values are joined and matched against Regex
+	Separator    string   // separator of the joined source values (default: ;)
+	Regex        string   // anchored regular expression (default: (.*))
+	TargetLabel  string   // label written by replace and hashmod
+	Replacement  *string  // replacement of replace and labelmap (default: $1)
+	Modulus      uint64   // modulus of hashmod
+	Action       string   // replace, keep, drop, hashmod, labelmap, labeldrop or labelkeep (default: replace)
+}
+
+// RelabelRule is a compiled relabeling rule
+type RelabelRule struct {
+	RelabelConfig
+	regex       *regexp.Regexp
+	replacement string
+}
+
+// RelabelRules are applied in order to every gathered sample
+var RelabelRules []*RelabelRule
+
+// NewRelabelRule validates and compiles a relabeling rule.
+func NewRelabelRule(cfg RelabelConfig) (*RelabelRule, error) {
+	r := &RelabelRule{
+		RelabelConfig: cfg,
+		replacement:   "$1",
+	}
+
+	if r.Action == "" {
+		r.Action = "replace"
+	}
+	if r.Separator == "" {
+		r.Separator = ";"
+	}
+	if r.Regex == "" {
+		r.Regex = "(.*)"
+	}
+	if r.Replacement != nil {
+		r.replacement = *r.Replacement
+	}
+
+	regex, err := regexp.Compile("^(?:" + r.Regex + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("invalid relabel regex '%s': %v", r.Regex, err)
+	}
+	r.regex = regex
+
+	switch r.Action {
+	case "replace":
+		if r.TargetLabel == "" {
+			return nil, fmt.Errorf("relabel action replace requires a target_label")
+		}
+	case "hashmod":
+		if r.TargetLabel == "" || r.Modulus == 0 {
+			return nil, fmt.Errorf("relabel action hashmod requires a target_label and a modulus")
+		}
+	case "keep", "drop", "labelmap", "labeldrop", "labelkeep":
+	default:
+		return nil, fmt.Errorf("unknown relabel action '%s'", r.Action)
+	}
+
+	return r, nil
+}
+
+// Relabel applies the rules to a sample, returning false when it is dropped.
+//
+// The class is exposed to the rules as the __class__ label. Labels starting
+// with __ are removed once all the rules are applied, so they can be used as
+// temporary labels.
+func Relabel(s Sample, rules []*RelabelRule) (Sample, bool) {
+	if len(rules) == 0 {
+		return s, true
+	}
+
+	labels := s.Labels.Copy()
+	labels[ClassLabel] = s.Class
+
+	for _, r := range rules {
+		if !r.apply(labels) {
+			return s, false
+		}
+	}
+
+	s.Class = labels[ClassLabel]
+	if s.Class == "" {
+		return s, false
+	}
+
+	for k := range labels {
+		if strings.HasPrefix(k, "__") {
+			delete(labels, k)
+		}
+	}
+	s.Labels = labels
+
+	return s, true
+}
+
+func (r *RelabelRule) apply(labels Labels) bool {
+	values := make([]string, 0, len(r.SourceLabels))
+	for _, k := range r.SourceLabels {
+		values = append(values, labels[k])
+	}
+	value := strings.Join(values, r.Separator)
+
+	switch r.Action {
+	case "keep":
+		return r.regex.MatchString(value)
+
+	case "drop":
+		return !r.regex.MatchString(value)
+
+	case "replace":
+		match := r.regex.FindStringSubmatchIndex(value)
+		if match == nil {
+			return true
+		}
+		target := string(r.regex.ExpandString(nil, r.TargetLabel, value, match))
+		res := string(r.regex.ExpandString(nil, r.replacement, value, match))
+		if res == "" {
+			delete(labels, target)
+		} else {
+			labels[target] = res
+		}
+
+	case "hashmod":
+		sum := md5.Sum([]byte(value))
+		labels[r.TargetLabel] = fmt.Sprintf("%d", binary.BigEndian.Uint64(sum[8:])%r.Modulus)
+
+	case "labelmap":
+		for _, k := range labels.Keys() {
+			if k != ClassLabel && r.regex.MatchString(k) {
+				labels[r.regex.ReplaceAllString(k, r.replacement)] = labels[k]
+			}
+		}
+
+	case "labeldrop":
+		for k := range labels {
+			if k != ClassLabel && r.regex.MatchString(k) {
+				delete(labels, k)
+			}
+		}
+
+	case "labelkeep":
+		for k := range labels {
+			if k != ClassLabel && !r.regex.MatchString(k) {
+				delete(labels, k)
+			}
+		}
+	}
+
+	return true
+}
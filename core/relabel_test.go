@@ -0,0 +1,177 @@
+package core
+
+import (
+	"testing"
+)
+
+func strPtr(s string) *string {
+	return &s
+}
+
+func TestRelabel(t *testing.T) {
+	sample := NewGauge(1, "os.net.bytes", Labels{"iface": "eth0", "direction": "in"}, 1)
+
+	tests := []struct {
+		name    string
+		configs []RelabelConfig
+		class   string
+		labels  Labels // nil when the sample is dropped
+	}{
+		{
+			name:   "no rules",
+			class:  "os.net.bytes",
+			labels: Labels{"iface": "eth0", "direction": "in"},
+		},
+		{
+			name:    "keep matching",
+			configs: []RelabelConfig{{SourceLabels: []string{"iface"}, Regex: "eth.*", Action: "keep"}},
+			class:   "os.net.bytes",
+			labels:  Labels{"iface": "eth0", "direction": "in"},
+		},
+		{
+			name:    "keep anchored",
+			configs: []RelabelConfig{{SourceLabels: []string{"iface"}, Regex: "eth", Action: "keep"}},
+		},
+		{
+			name:    "drop on class",
+			configs: []RelabelConfig{{SourceLabels: []string{ClassLabel}, Regex: "os\\.net\\..*", Action: "drop"}},
+		},
+		{
+			name:    "drop not matching",
+			configs: []RelabelConfig{{SourceLabels: []string{"iface"}, Regex: "lo", Action: "drop"}},
+			class:   "os.net.bytes",
+			labels:  Labels{"iface": "eth0", "direction": "in"},
+		},
+		{
+			name: "replace joined sources",
+			configs: []RelabelConfig{{
+				SourceLabels: []string{"iface", "direction"},
+				Separator:    "/",
+				Regex:        "(.*)/(.*)",
+				TargetLabel:  "link",
+				Replacement:  strPtr("$2-$1"),
+			}},
+			class:  "os.net.bytes",
+			labels: Labels{"iface": "eth0", "direction": "in", "link": "in-eth0"},
+		},
+		{
+			name:    "replace default",
+			configs: []RelabelConfig{{SourceLabels: []string{"iface"}, TargetLabel: "device"}},
+			class:   "os.net.bytes",
+			labels:  Labels{"iface": "eth0", "direction": "in", "device": "eth0"},
+		},
+		{
+			name:    "replace not matching",
+			configs: []RelabelConfig{{SourceLabels: []string{"iface"}, Regex: "lo", TargetLabel: "device"}},
+			class:   "os.net.bytes",
+			labels:  Labels{"iface": "eth0", "direction": "in"},
+		},
+		{
+			name:    "replace with empty deletes",
+			configs: []RelabelConfig{{SourceLabels: []string{"missing"}, TargetLabel: "iface"}},
+			class:   "os.net.bytes",
+			labels:  Labels{"direction": "in"},
+		},
+		{
+			name: "rename class",
+			configs: []RelabelConfig{{
+				SourceLabels: []string{ClassLabel},
+				Regex:        "os\\.(.*)",
+				TargetLabel:  ClassLabel,
+				Replacement:  strPtr("node.$1"),
+			}},
+			class:  "node.net.bytes",
+			labels: Labels{"iface": "eth0", "direction": "in"},
+		},
+		{
+			name:    "empty class drops",
+			configs: []RelabelConfig{{SourceLabels: []string{"missing"}, TargetLabel: ClassLabel}},
+		},
+		{
+			name:    "hashmod",
+			configs: []RelabelConfig{{SourceLabels: []string{"iface"}, TargetLabel: "shard", Modulus: 4, Action: "hashmod"}},
+			class:   "os.net.bytes",
+			labels:  Labels{"iface": "eth0", "direction": "in", "shard": "1"},
+		},
+		{
+			name:    "labelmap",
+			configs: []RelabelConfig{{Regex: "(i.*)", Replacement: strPtr("net_$1"), Action: "labelmap"}},
+			class:   "os.net.bytes",
+			labels:  Labels{"iface": "eth0", "direction": "in", "net_iface": "eth0"},
+		},
+		{
+			name:    "labeldrop",
+			configs: []RelabelConfig{{Regex: "dir.*", Action: "labeldrop"}},
+			class:   "os.net.bytes",
+			labels:  Labels{"iface": "eth0"},
+		},
+		{
+			name:    "labelkeep",
+			configs: []RelabelConfig{{Regex: "iface", Action: "labelkeep"}},
+			class:   "os.net.bytes",
+			labels:  Labels{"iface": "eth0"},
+		},
+		{
+			name: "temporary labels",
+			configs: []RelabelConfig{
+				{SourceLabels: []string{"iface"}, TargetLabel: "__tmp"},
+				{SourceLabels: []string{"__tmp"}, TargetLabel: "device"},
+			},
+			class:  "os.net.bytes",
+			labels: Labels{"iface": "eth0", "direction": "in", "device": "eth0"},
+		},
+	}
+
+	for _, tt := range tests {
+		var rules []*RelabelRule
+		for _, cfg := range tt.configs {
+			r, err := NewRelabelRule(cfg)
+			if err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+			rules = append(rules, r)
+		}
+
+		got, ok := Relabel(sample, rules)
+		if ok != (tt.labels != nil) {
+			t.Errorf("%s: kept = %v, want %v", tt.name, ok, tt.labels != nil)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if got.Class != tt.class {
+			t.Errorf("%s: class = %s, want %s", tt.name, got.Class, tt.class)
+		}
+		if len(got.Labels) != len(tt.labels) {
+			t.Errorf("%s: labels = %v, want %v", tt.name, got.Labels, tt.labels)
+			continue
+		}
+		for k, v := range tt.labels {
+			if got.Labels[k] != v {
+				t.Errorf("%s: labels = %v, want %v", tt.name, got.Labels, tt.labels)
+				break
+			}
+		}
+	}
+
+	if len(sample.Labels) != 2 {
+		t.Errorf("relabeling altered the sample labels: %v", sample.Labels)
+	}
+}
+
+func TestNewRelabelRuleErrors(t *testing.T) {
+	tests := []RelabelConfig{
+		{Regex: "(", TargetLabel: "a"},
+		{Action: "replace"},
+		{Action: "hashmod", TargetLabel: "shard"},
+		{Action: "hashmod", Modulus: 4},
+		{Action: "unknown"},
+	}
+
+	for _, cfg := range tests {
+		if _, err := NewRelabelRule(cfg); err == nil {
+			t.Errorf("invalid rule %+v accepted", cfg)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// Kind is the metric kind of a sample
+type Kind uint8
+
+const (
+	// Gauge is a value that can go up and down
+	Gauge Kind = iota
+	// Counter is a cumulative value that only increases, until a reset
+	Counter
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Counter:
+		return "counter"
+	default:
+		return "gauge"
+	}
+}
+
+// Labels is a set of series labels
+type Labels map[string]string
+
+// Keys returns the label keys in a stable order
+func (l Labels) Keys() []string {
+	keys := make([]string, 0, len(l))
+	for k := range l {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Copy returns a copy of the label set
+func (l Labels) Copy() Labels {
+	res := make(Labels, len(l))
+	for k, v := range l {
+		res[k] = v
+	}
+	return res
+}
+
+// Sample is a single collected data point.
+//
+// Value is one of int64, uint64, float64, bool or string. A counter with an
+// uint32 value is a 32 bits counter that wraps instead of being reset.
+// Timestamp is expressed in microseconds since epoch.
+// Unit and Help are filled from the class description when the sample is gathered.
+type Sample struct {
+	Class      string
+	Labels     Labels
+	Attributes Labels
+	Timestamp  int64
+	Value      interface{}
+	Kind       Kind
+	Unit       Unit
+	Help       string
+}
+
+// NewGauge returns a gauge sample
+func NewGauge(tick int64, class string, labels Labels, value interface{}) Sample {
+	return Sample{
+		Class:     class,
+		Labels:    labels,
+		Timestamp: tick,
+		Value:     value,
+		Kind:      Gauge,
+	}
+}
+
+// NewCounter returns a counter sample
+func NewCounter(tick int64, class string, labels Labels, value interface{}) Sample {
+	return Sample{
+		Class:     class,
+		Labels:    labels,
+		Timestamp: tick,
+		Value:     value,
+		Kind:      Counter,
+	}
+}
+
+// Float returns the sample value as a float64, ok is false for string values
+func (s Sample) Float() (f float64, ok bool) {
+	switch v := s.Value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	default:
+		return 0, false
+	}
+}
+
+// FormatValue renders a numeric or boolean sample value as text
+func (s Sample) FormatValue() string {
+	switch v := s.Value.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'g', -1, 32)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
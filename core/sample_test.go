@@ -0,0 +1,76 @@
+package core
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSampleFloat(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  float64
+		ok    bool
+	}{
+		{42.5, 42.5, true},
+		{float32(1.5), 1.5, true},
+		{int64(-3), -3, true},
+		{int(7), 7, true},
+		{uint64(math.MaxUint32 + 1), math.MaxUint32 + 1, true},
+		{uint32(8), 8, true},
+		{int32(-9), -9, true},
+		{true, 1, true},
+		{false, 0, true},
+		{"active", 0, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := NewGauge(0, "c", nil, tt.value).Float()
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("Float(%#v) = %v, %v, want %v, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestSampleFormatValue(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  string
+	}{
+		{42.5, "42.5"},
+		{1e21, "1e+21"},
+		{float32(0.1), "0.1"},
+		{uint64(18446744073709551615), "18446744073709551615"},
+		{int64(-1), "-1"},
+		{true, "true"},
+	}
+
+	for _, tt := range tests {
+		if got := NewGauge(0, "c", nil, tt.value).FormatValue(); got != tt.want {
+			t.Errorf("FormatValue(%#v) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestKindString(t *testing.T) {
+	if got := NewCounter(0, "c", nil, 1).Kind.String(); got != "counter" {
+		t.Errorf("counter kind = %q", got)
+	}
+	if got := NewGauge(0, "c", nil, 1).Kind.String(); got != "gauge" {
+		t.Errorf("gauge kind = %q", got)
+	}
+}
+
+func TestLabelsKeysAndCopy(t *testing.T) {
+	labels := Labels{"b": "2", "a": "1", "c": "3"}
+
+	keys := labels.Keys()
+	if len(keys) != 3 || keys[0] != "a" || keys[1] != "b" || keys[2] != "c" {
+		t.Errorf("Keys() = %v, want sorted keys", keys)
+	}
+
+	cp := labels.Copy()
+	cp["a"] = "changed"
+	if labels["a"] != "1" {
+		t.Error("Copy() shares the label set")
+	}
+}
@@ -0,0 +1,67 @@
+package core
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+)
+
+func init() {
+	RegisterEncoder("sensision", &SensisionEncoder{})
+}
+
+// SensisionEncoder renders samples as Warp10 Sensision (GTS input format)
+type SensisionEncoder struct{}
+
+// ContentType of the Sensision output
+func (e *SensisionEncoder) ContentType() string {
+	return "text/plain; charset=utf-8"
+}
+
+// Encode writes samples as Sensision lines
+func (e *SensisionEncoder) Encode(w io.Writer, samples []Sample) error {
+	bw := bufio.NewWriter(w)
+	for _, s := range samples {
+		if _, err := bw.WriteString(ToSensision(s)); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+var sensisionReplacer = strings.NewReplacer(
+	",", "%2C",
+	"{", "%7B",
+	"}", "%7D",
+	"=", "%3D",
+	" ", "%20",
+	"\n", "%0A",
+)
+
+// ToSensision renders a sample as a Sensision line
+func ToSensision(s Sample) string {
+	gtsValue := ""
+	switch v := s.Value.(type) {
+	case string:
+		gtsValue = fmt.Sprintf("'%v'", url.PathEscape(v))
+	default:
+		gtsValue = s.FormatValue()
+	}
+
+	class := sensisionReplacer.Replace(s.Class)
+
+	if len(s.Attributes) > 0 {
+		return fmt.Sprintf("%v// %v%v%v %v\n", s.Timestamp, class, sensisionLabels(s.Labels), sensisionLabels(s.Attributes), gtsValue)
+	}
+	return fmt.Sprintf("%v// %v%v %v\n", s.Timestamp, class, sensisionLabels(s.Labels), gtsValue)
+}
+
+func sensisionLabels(labels Labels) string {
+	pairs := make([]string, 0, len(labels))
+	for _, k := range labels.Keys() {
+		pairs = append(pairs, sensisionReplacer.Replace(k)+"="+sensisionReplacer.Replace(labels[k]))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
@@ -0,0 +1,6 @@
+package core
+
+// Sink pushes samples to a remote backend
+type Sink interface {
+	Push(samples []Sample) error
+}
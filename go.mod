@@ -6,6 +6,8 @@ require (
 	github.com/StackExchange/wmi v0.0.0-20180116203802-5d049714c4a6 // indirect
 	github.com/fsnotify/fsnotify v0.0.0-20161026203122-fd9ec7deca8b
 	github.com/go-ole/go-ole v0.0.0-20180213002836-a1ec82a652eb // indirect
+	github.com/godbus/dbus/v5 v5.1.0
+	github.com/golang/snappy v0.0.4
 	github.com/hashicorp/hcl v0.0.0-20161201141704-37ab263305aa // indirect
 	github.com/inconshreveable/mousetrap v1.0.0 // indirect
 	github.com/magiconair/properties v0.0.0-20161128003514-9c47895dc1ce // indirect
@@ -16,12 +18,12 @@ require (
 	github.com/shirou/w32 v0.0.0-20160930032740-bb4de0191aa4 // indirect
 	github.com/sirupsen/logrus v0.11.5
 	github.com/spf13/afero v0.0.0-20161208182142-2f30b2a92c0e // indirect
-	github.com/spf13/cast v0.0.0-20161116013354-24b6558033ff // indirect
+	github.com/spf13/cast v0.0.0-20161116013354-24b6558033ff
 	github.com/spf13/cobra v0.0.0-20161116132053-9495bc009a56
 	github.com/spf13/jwalterweatherman v0.0.0-20160311093646-33c24e77fb80 // indirect
 	github.com/spf13/pflag v0.0.0-20161024131444-5ccb023bc27d // indirect
 	github.com/spf13/viper v0.0.0-20161213093849-5ed0fc31f7f4
 	github.com/stretchr/testify v1.4.0 // indirect
+	golang.org/x/net v0.0.0-20190620200207-3b0461eec859
 	golang.org/x/sys v0.0.0-20190626221950-04f50cda93cb // indirect
-	golang.org/x/text v0.0.0-20161209224335-47a200a05c8b // indirect
 )
@@ -0,0 +1,269 @@
+package sinks
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ovh/noderig/core"
+)
+
+// GraphiteOptions configures the Graphite sink
+type GraphiteOptions struct {
+	Address  string // carbon address, e.g. graphite.example.com:2003
+	Protocol string // tcp or udp
+	Format   string // plaintext or pickle (tcp only)
+	Template string // series path template
+	Timeout  uint   // dial and write timeout (ms)
+}
+
+// Graphite streams samples to a carbon daemon.
+//
+// Series paths are built from a template where {class} is replaced by the
+// classname, {<key>} by the value of the label <key>, and {labels} by the
+// values of all the labels not used elsewhere in the template, sorted by key.
+type Graphite struct {
+	opts GraphiteOptions
+	used map[string]bool
+
+	mutex sync.Mutex
+	conn  net.Conn
+}
+
+// Graphite datagrams are kept under the usual MTU
+const graphiteDatagramSize = 1400
+
+var graphitePlaceholder = regexp.MustCompile(`\{[^{}]+\}`)
+
+// NewGraphite returns an initialized Graphite sink.
+func NewGraphite(opts GraphiteOptions) (*Graphite, error) {
+	if opts.Address == "" {
+		return nil, fmt.Errorf("graphite address is required")
+	}
+	if opts.Protocol != "tcp" && opts.Protocol != "udp" {
+		return nil, fmt.Errorf("graphite protocol must be tcp or udp, got '%s'", opts.Protocol)
+	}
+	if opts.Format != "plaintext" && opts.Format != "pickle" {
+		return nil, fmt.Errorf("graphite format must be plaintext or pickle, got '%s'", opts.Format)
+	}
+	if opts.Format == "pickle" && opts.Protocol == "udp" {
+		return nil, fmt.Errorf("graphite pickle format requires tcp")
+	}
+	if opts.Template == "" {
+		opts.Template = "{class}.{labels}"
+	}
+
+	s := &Graphite{
+		opts: opts,
+		used: make(map[string]bool),
+	}
+	for _, p := range graphitePlaceholder.FindAllString(opts.Template, -1) {
+		s.used[strings.Trim(p, "{}")] = true
+	}
+
+	return s, nil
+}
+
+type graphitePoint struct {
+	path  string
+	value float64
+	tick  int64
+}
+
+// Push sends samples to carbon, reconnecting once on a broken TCP connection.
+func (s *Graphite) Push(samples []core.Sample) error {
+	points := make([]graphitePoint, 0, len(samples))
+	for _, smp := range samples {
+		value, ok := smp.Float()
+		if !ok || math.IsNaN(value) || math.IsInf(value, 0) {
+			continue // graphite only stores numbers
+		}
+		points = append(points, graphitePoint{path: s.path(smp), value: value, tick: smp.Timestamp / 1000000})
+	}
+	if len(points) == 0 {
+		return nil
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.opts.Protocol == "udp" {
+		return s.sendDatagrams(points)
+	}
+
+	var payload []byte
+	if s.opts.Format == "pickle" {
+		payload = graphitePickle(points)
+	} else {
+		payload = graphitePlaintext(points)
+	}
+
+	err := s.write(payload)
+	if err != nil {
+		s.close()
+		err = s.write(payload)
+	}
+	return err
+}
+
+func (s *Graphite) write(payload []byte) error {
+	if s.conn == nil {
+		conn, err := net.DialTimeout(s.opts.Protocol, s.opts.Address, s.timeout())
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+	}
+
+	if err := s.conn.SetWriteDeadline(time.Now().Add(s.timeout())); err != nil {
+		return err
+	}
+	_, err := s.conn.Write(payload)
+	return err
+}
+
+func (s *Graphite) close() {
+	if s.conn != nil {
+		_ = s.conn.Close()
+		s.conn = nil
+	}
+}
+
+func (s *Graphite) timeout() time.Duration {
+	if s.opts.Timeout == 0 {
+		return 5 * time.Second
+	}
+	return time.Duration(s.opts.Timeout) * time.Millisecond
+}
+
+// sendDatagrams splits plaintext lines into datagrams
+func (s *Graphite) sendDatagrams(points []graphitePoint) error {
+	var buf bytes.Buffer
+	for _, p := range points {
+		line := graphitePlaintext([]graphitePoint{p})
+		if buf.Len() > 0 && buf.Len()+len(line) > graphiteDatagramSize {
+			if err := s.write(buf.Bytes()); err != nil {
+				s.close()
+				return err
+			}
+			buf.Reset()
+		}
+		buf.Write(line)
+	}
+
+	if err := s.write(buf.Bytes()); err != nil {
+		s.close()
+		return err
+	}
+	return nil
+}
+
+// path renders the series path of a sample from the template
+func (s *Graphite) path(smp core.Sample) string {
+	rendered := graphitePlaceholder.ReplaceAllStringFunc(s.opts.Template, func(p string) string {
+		switch key := strings.Trim(p, "{}"); key {
+		case "class":
+			return graphiteClass(smp.Class)
+		case "labels":
+			var values []string
+			for _, k := range smp.Labels.Keys() {
+				if !s.used[k] {
+					values = append(values, graphiteNode(smp.Labels[k]))
+				}
+			}
+			return strings.Join(values, ".")
+		default:
+			return graphiteNode(smp.Labels[key])
+		}
+	})
+
+	// drop the empty nodes left by missing labels
+	var nodes []string
+	for _, node := range strings.Split(rendered, ".") {
+		if node != "" {
+			nodes = append(nodes, node)
+		}
+	}
+	return strings.Join(nodes, ".")
+}
+
+var (
+	graphiteNodeReplacer  = regexp.MustCompile(`[^a-zA-Z0-9_\-]+`)
+	graphiteClassReplacer = regexp.MustCompile(`[^a-zA-Z0-9_\-.]+`)
+)
+
+// graphiteNode sanitizes a value into a single path node
+func graphiteNode(v string) string {
+	return strings.Trim(graphiteNodeReplacer.ReplaceAllString(v, "_"), "_")
+}
+
+// graphiteClass sanitizes a classname, keeping its dots as path separators
+func graphiteClass(class string) string {
+	return graphiteClassReplacer.ReplaceAllString(class, "_")
+}
+
+func graphitePlaintext(points []graphitePoint) []byte {
+	var buf bytes.Buffer
+	for _, p := range points {
+		buf.WriteString(p.path + " " + strconv.FormatFloat(p.value, 'f', -1, 64) + " " + strconv.FormatInt(p.tick, 10) + "\n")
+	}
+	return buf.Bytes()
+}
+
+// Pickle opcodes (protocol 2)
+const (
+	pickleProto      = 0x80
+	pickleEmptyList  = ']'
+	pickleMark       = '('
+	pickleBinUnicode = 'X'
+	pickleBinInt     = 'J'
+	pickleLong1      = 0x8a
+	pickleBinFloat   = 'G'
+	pickleTuple2     = 0x86
+	pickleAppends    = 'e'
+	pickleStop       = '.'
+)
+
+// graphitePickle encodes points as a length-prefixed pickled list of
+// (path, (timestamp, value)) tuples, as read by the carbon pickle receiver.
+func graphitePickle(points []graphitePoint) []byte {
+	var buf bytes.Buffer
+	buf.Write([]byte{pickleProto, 2, pickleEmptyList, pickleMark})
+
+	var b4 [4]byte
+	var b8 [8]byte
+	for _, p := range points {
+		buf.WriteByte(pickleBinUnicode)
+		binary.LittleEndian.PutUint32(b4[:], uint32(len(p.path)))
+		buf.Write(b4[:])
+		buf.WriteString(p.path)
+
+		if p.tick >= math.MinInt32 && p.tick <= math.MaxInt32 {
+			buf.WriteByte(pickleBinInt)
+			binary.LittleEndian.PutUint32(b4[:], uint32(int32(p.tick)))
+			buf.Write(b4[:])
+		} else {
+			buf.Write([]byte{pickleLong1, 8})
+			binary.LittleEndian.PutUint64(b8[:], uint64(p.tick))
+			buf.Write(b8[:])
+		}
+
+		buf.WriteByte(pickleBinFloat)
+		binary.BigEndian.PutUint64(b8[:], math.Float64bits(p.value))
+		buf.Write(b8[:])
+
+		buf.Write([]byte{pickleTuple2, pickleTuple2})
+	}
+	buf.Write([]byte{pickleAppends, pickleStop})
+
+	res := make([]byte, 4, 4+buf.Len())
+	binary.BigEndian.PutUint32(res, uint32(buf.Len()))
+	return append(res, buf.Bytes()...)
+}
@@ -0,0 +1,171 @@
+package sinks
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ovh/noderig/core"
+)
+
+func TestGraphitePath(t *testing.T) {
+	tests := []struct {
+		template string
+		class    string
+		labels   core.Labels
+		want     string
+	}{
+		{"", "os.load1", nil, "os.load1"},
+		{"", "os.net.bytes", core.Labels{"iface": "eth0", "direction": "in"}, "os.net.bytes.in.eth0"},
+		{"{class}", "os.disk fs/used", core.Labels{"disk": "sda"}, "os.disk_fs_used"},
+		{"servers.{host}.{class}", "os.load1", core.Labels{"host": "web-1"}, "servers.web-1.os.load1"},
+		{"{host}.{class}.{labels}", "os.net.bytes", core.Labels{"host": "web-1", "iface": "eth0", "direction": "in"}, "web-1.os.net.bytes.in.eth0"},
+		{"{class}.{mount}", "os.disk.fs.used", core.Labels{"mount": "/var/lib"}, "os.disk.fs.used.var_lib"},
+
+		// missing labels leave no empty node
+		{"{host}.{class}.{labels}", "os.load1", nil, "os.load1"},
+		{"{class}.{labels}", "os.net.bytes", core.Labels{"iface": "", "direction": "in"}, "os.net.bytes.in"},
+	}
+
+	for _, tt := range tests {
+		s, err := NewGraphite(GraphiteOptions{Address: "localhost:2003", Protocol: "tcp", Format: "plaintext", Template: tt.template})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := s.path(core.NewGauge(1, tt.class, tt.labels, 1.0)); got != tt.want {
+			t.Errorf("%q with %s%v: got %q, want %q", tt.template, tt.class, tt.labels, got, tt.want)
+		}
+	}
+}
+
+func TestGraphitePickle(t *testing.T) {
+	got := graphitePickle([]graphitePoint{
+		{path: "a.b", value: 1.5, tick: 1000},
+		{path: "c", value: 2, tick: 1 << 32},
+	})
+
+	want := []byte{
+		0x00, 0x00, 0x00, 0x39, // big-endian length prefix
+		0x80, 0x02, ']', '(', // protocol 2, empty list, mark
+		'X', 0x03, 0x00, 0x00, 0x00, 'a', '.', 'b', // path
+		'J', 0xe8, 0x03, 0x00, 0x00, // BININT timestamp
+		'G', 0x3f, 0xf8, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, // big-endian BINFLOAT value
+		0x86, 0x86, // (timestamp, value) and (path, ...) tuples
+		'X', 0x01, 0x00, 0x00, 0x00, 'c',
+		0x8a, 0x08, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, // LONG1 timestamp
+		'G', 0x40, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x86, 0x86,
+		'e', '.', // appends, stop
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("got\n% x\nwant\n% x", got, want)
+	}
+}
+
+func TestGraphiteDatagrams(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	s, err := NewGraphite(GraphiteOptions{Address: conn.LocalAddr().String(), Protocol: "udp", Format: "plaintext"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 100 bytes lines, 14 of them fit in a datagram
+	class := strings.Repeat("a", 95)
+	samples := make([]core.Sample, 30)
+	for i := range samples {
+		samples[i] = core.NewGauge(1000000, class, nil, 1.0)
+	}
+	if err := s.Push(samples); err != nil {
+		t.Fatal(err)
+	}
+
+	line := class + " 1 1\n"
+	for i, lines := range []int{14, 14, 2} {
+		buf := make([]byte, 2048)
+		_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := strings.Repeat(line, lines); string(buf[:n]) != want {
+			t.Errorf("datagram %d: got %d bytes, want %d lines", i, n, lines)
+		}
+	}
+}
+
+func TestGraphiteReconnect(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	received := make(chan string)
+	go func() {
+		// the first connection is reset after one read
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		buf := make([]byte, 1024)
+		n, _ := conn.Read(buf)
+		_ = conn.(*net.TCPConn).SetLinger(0)
+		conn.Close()
+		received <- string(buf[:n])
+
+		conn, err = ln.Accept()
+		if err != nil {
+			return
+		}
+		b, _ := ioutil.ReadAll(conn)
+		conn.Close()
+		received <- string(b)
+	}()
+
+	s, err := NewGraphite(GraphiteOptions{Address: ln.Addr().String(), Protocol: "tcp", Format: "plaintext"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Push([]core.Sample{core.NewGauge(1000000, "os.load1", nil, 1.0)}); err != nil {
+		t.Fatal(err)
+	}
+	if got := <-received; got != "os.load1 1 1\n" {
+		t.Errorf("first connection got %q", got)
+	}
+
+	// wait for the reset to reach the client
+	_ = s.conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := s.conn.Read(make([]byte, 1)); err == nil {
+		t.Fatal("first connection not reset")
+	}
+
+	if err := s.Push([]core.Sample{core.NewGauge(2000000, "os.load1", nil, 2.0)}); err != nil {
+		t.Fatal(err)
+	}
+	s.close()
+	if got := <-received; got != "os.load1 2 2\n" {
+		t.Errorf("second connection got %q", got)
+	}
+}
+
+func TestNewGraphiteOptions(t *testing.T) {
+	for _, opts := range []GraphiteOptions{
+		{Protocol: "tcp", Format: "plaintext"},
+		{Address: "localhost:2003", Protocol: "http", Format: "plaintext"},
+		{Address: "localhost:2003", Protocol: "tcp", Format: "json"},
+		{Address: "localhost:2003", Protocol: "udp", Format: "pickle"},
+	} {
+		if _, err := NewGraphite(opts); err == nil {
+			t.Errorf("%+v accepted", opts)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package sinks
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+func gzipPayload(payload []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(payload); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// checkResponse turns an unexpected status into an error, client errors
+// being permanent except for throttling.
+func checkResponse(res *http.Response) error {
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		_, _ = io.Copy(ioutil.Discard, res.Body)
+		return nil
+	}
+
+	msg, _ := ioutil.ReadAll(io.LimitReader(res.Body, 512))
+	err := fmt.Errorf("%s: %s", res.Status, bytes.TrimSpace(msg))
+	if res.StatusCode >= 400 && res.StatusCode < 500 && res.StatusCode != http.StatusTooManyRequests {
+		return permanent(err)
+	}
+	return err
+}
@@ -0,0 +1,107 @@
+package sinks
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ovh/noderig/core"
+)
+
+// InfluxOptions configures the InfluxDB v2 sink
+type InfluxOptions struct {
+	URL       string // InfluxDB base URL, e.g. http://localhost:8086
+	Org       string // organization name or id
+	Bucket    string // destination bucket
+	Token     string // API token
+	Gzip      bool   // compress batches
+	BatchSize int    // maximum number of points per request
+	Timeout   uint   // request timeout (ms)
+	Retries   int    // retries before dropping a batch
+	Backoff   uint   // first retry delay (ms), doubled on each retry
+	MaxWait   uint   // maximum retry delay (ms)
+}
+
+// Influx pushes samples as line protocol to an InfluxDB v2 write endpoint
+type Influx struct {
+	opts   InfluxOptions
+	url    string
+	client *http.Client
+	retry  retry
+}
+
+// NewInflux returns an initialized InfluxDB v2 sink.
+func NewInflux(opts InfluxOptions) (*Influx, error) {
+	if opts.URL == "" || opts.Bucket == "" || opts.Org == "" {
+		return nil, fmt.Errorf("influx url, org and bucket are required")
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 5000
+	}
+
+	query := url.Values{}
+	query.Set("org", opts.Org)
+	query.Set("bucket", opts.Bucket)
+	query.Set("precision", "ns")
+
+	return &Influx{
+		opts:   opts,
+		url:    strings.TrimSuffix(opts.URL, "/") + "/api/v2/write?" + query.Encode(),
+		client: &http.Client{Timeout: time.Duration(opts.Timeout) * time.Millisecond},
+		retry:  newRetry(opts.Retries, opts.Backoff, opts.MaxWait),
+	}, nil
+}
+
+// Push sends samples to InfluxDB, in batches.
+func (s *Influx) Push(samples []core.Sample) error {
+	lines := core.ToInflux(samples)
+
+	var err error
+	for len(lines) > 0 {
+		n := s.opts.BatchSize
+		if n > len(lines) {
+			n = len(lines)
+		}
+
+		payload := []byte(strings.Join(lines[:n], "\n") + "\n")
+		lines = lines[n:]
+
+		if perr := s.retry.do("Influx", func() error {
+			return s.send(payload)
+		}); perr != nil {
+			err = perr
+		}
+	}
+	return err
+}
+
+func (s *Influx) send(payload []byte) error {
+	body := payload
+	if s.opts.Gzip {
+		var err error
+		if body, err = gzipPayload(payload); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest("POST", s.url, bytes.NewReader(body))
+	if err != nil {
+		return permanent(err)
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	req.Header.Set("Authorization", "Token "+s.opts.Token)
+	if s.opts.Gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	return checkResponse(res)
+}
@@ -0,0 +1,429 @@
+package sinks
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http2"
+
+	"github.com/ovh/noderig/core"
+)
+
+// OTLPOptions configures the OpenTelemetry OTLP sink
+type OTLPOptions struct {
+	Endpoint string            // collector endpoint, e.g. http://otel:4318 (http) or otel:4317 (grpc)
+	Protocol string            // grpc or http
+	Insecure bool              // plaintext gRPC when the endpoint has no scheme
+	Headers  map[string]string // additional request headers (authentication)
+	Gzip     bool              // compress requests
+	Resource core.Labels       // resource attributes
+	Version  string            // noderig version, set on the instrumentation scope
+	Timeout  uint              // request timeout (ms)
+	Retries  int               // retries before dropping a batch
+	Backoff  uint              // first retry delay (ms), doubled on each retry
+	MaxWait  uint              // maximum retry delay (ms)
+}
+
+// OTLP exports samples to an OpenTelemetry collector, gauges as OTLP gauges
+// and counters as monotonic cumulative sums.
+//
+// The sums start when Noderig first sees a series, and start again whenever
+// the value goes backwards (counter reset).
+type OTLP struct {
+	opts   OTLPOptions
+	url    string
+	client *http.Client
+	retry  retry
+
+	mutex  sync.Mutex
+	starts map[string]otlpStart
+}
+
+// otlpStart is the start of a cumulative sum
+type otlpStart struct {
+	time  int64   // unix ns
+	value float64 // last pushed value
+}
+
+const otlpGRPCMethod = "/opentelemetry.proto.collector.metrics.v1.MetricsService/Export"
+
+// NewOTLP returns an initialized OTLP sink.
+func NewOTLP(opts OTLPOptions) (*OTLP, error) {
+	if opts.Endpoint == "" {
+		return nil, fmt.Errorf("otlp endpoint is required")
+	}
+
+	s := &OTLP{
+		opts:   opts,
+		retry:  newRetry(opts.Retries, opts.Backoff, opts.MaxWait),
+		starts: make(map[string]otlpStart),
+	}
+	timeout := time.Duration(opts.Timeout) * time.Millisecond
+
+	switch opts.Protocol {
+	case "http":
+		endpoint := strings.TrimSuffix(opts.Endpoint, "/")
+		if !strings.Contains(endpoint, "://") {
+			endpoint = "https://" + endpoint
+		}
+		if !strings.HasSuffix(endpoint, "/v1/metrics") {
+			endpoint += "/v1/metrics"
+		}
+		s.url = endpoint
+		s.client = &http.Client{Timeout: timeout}
+
+	case "grpc":
+		endpoint := opts.Endpoint
+		if !strings.Contains(endpoint, "://") {
+			if opts.Insecure {
+				endpoint = "http://" + endpoint
+			} else {
+				endpoint = "https://" + endpoint
+			}
+		}
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			return nil, err
+		}
+
+		transport := &http2.Transport{}
+		if u.Scheme == "http" {
+			// gRPC without TLS (h2c)
+			transport.AllowHTTP = true
+			transport.DialTLS = func(network, addr string, cfg *tls.Config) (net.Conn, error) {
+				return net.DialTimeout(network, addr, timeout)
+			}
+		}
+		s.url = u.Scheme + "://" + u.Host + otlpGRPCMethod
+		s.client = &http.Client{Timeout: timeout, Transport: transport}
+
+	default:
+		return nil, fmt.Errorf("otlp protocol must be grpc or http, got '%s'", opts.Protocol)
+	}
+
+	return s, nil
+}
+
+// Push exports the samples.
+func (s *OTLP) Push(samples []core.Sample) error {
+	payload := s.encode(samples)
+	if payload == nil {
+		return nil
+	}
+
+	return s.retry.do("OTLP", func() error {
+		if s.opts.Protocol == "grpc" {
+			return s.sendGRPC(payload)
+		}
+		return s.sendHTTP(payload)
+	})
+}
+
+func (s *OTLP) sendHTTP(payload []byte) error {
+	body := payload
+	if s.opts.Gzip {
+		var err error
+		if body, err = gzipPayload(payload); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest("POST", s.url, bytes.NewReader(body))
+	if err != nil {
+		return permanent(err)
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	if s.opts.Gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	for k, v := range s.opts.Headers {
+		req.Header.Set(k, v)
+	}
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	return checkResponse(res)
+}
+
+// gRPC status codes worth a retry
+var grpcRetryable = map[string]bool{
+	"1":  true, // CANCELLED
+	"4":  true, // DEADLINE_EXCEEDED
+	"8":  true, // RESOURCE_EXHAUSTED
+	"10": true, // ABORTED
+	"11": true, // OUT_OF_RANGE
+	"14": true, // UNAVAILABLE
+	"15": true, // DATA_LOSS
+}
+
+// sendGRPC performs an unary gRPC call, the message being framed with its
+// compression flag and length.
+func (s *OTLP) sendGRPC(payload []byte) error {
+	compressed := byte(0)
+	if s.opts.Gzip {
+		var err error
+		if payload, err = gzipPayload(payload); err != nil {
+			return err
+		}
+		compressed = 1
+	}
+
+	frame := make([]byte, 5, 5+len(payload))
+	frame[0] = compressed
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(payload)))
+	frame = append(frame, payload...)
+
+	req, err := http.NewRequest("POST", s.url, bytes.NewReader(frame))
+	if err != nil {
+		return permanent(err)
+	}
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("TE", "trailers")
+	if s.opts.Gzip {
+		req.Header.Set("Grpc-Encoding", "gzip")
+	}
+	for k, v := range s.opts.Headers {
+		req.Header.Set(k, v)
+	}
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	// trailers are only available once the body is consumed
+	_, _ = io.Copy(ioutil.Discard, res.Body)
+
+	if res.StatusCode != http.StatusOK {
+		return checkResponse(res)
+	}
+
+	status := res.Trailer.Get("Grpc-Status")
+	message := res.Trailer.Get("Grpc-Message")
+	if status == "" {
+		// trailers-only response
+		status = res.Header.Get("Grpc-Status")
+		message = res.Header.Get("Grpc-Message")
+	}
+	if status == "" || status == "0" {
+		return nil
+	}
+
+	if msg, err := url.PathUnescape(message); err == nil {
+		message = msg
+	}
+	err = fmt.Errorf("grpc status %s: %s", status, message)
+	if !grpcRetryable[status] {
+		return permanent(err)
+	}
+	return err
+}
+
+// OTLP aggregation temporality
+const otlpCumulative = 2
+
+type otlpPoint struct {
+	core.Sample
+	start int64 // start of the cumulative sum (unix ns)
+}
+
+type otlpMetric struct {
+	sample core.Sample
+	points []otlpPoint
+}
+
+// startTime returns the start of the cumulative sum of a counter sample,
+// recording it in starts.
+func (s *OTLP) startTime(smp core.Sample, starts map[string]otlpStart) int64 {
+	value, _ := smp.Float()
+
+	var key bytes.Buffer
+	key.WriteString(smp.Class)
+	for _, k := range smp.Labels.Keys() {
+		key.WriteString("\xff" + k + "\xff" + smp.Labels[k])
+	}
+
+	start, ok := starts[key.String()]
+	if !ok {
+		start, ok = s.starts[key.String()]
+	}
+	if !ok || value < start.value {
+		start.time = smp.Timestamp * 1000
+	}
+	start.value = value
+	starts[key.String()] = start
+
+	return start.time
+}
+
+// encode builds an ExportMetricsServiceRequest message
+func (s *OTLP) encode(samples []core.Sample) []byte {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// Only keep the series of this push, so that vanished series are evicted
+	starts := make(map[string]otlpStart, len(s.starts))
+	defer func() { s.starts = starts }()
+
+	var metrics []*otlpMetric
+	index := make(map[string]*otlpMetric)
+	for _, smp := range samples {
+		if _, ok := smp.Float(); !ok {
+			continue // OTLP has no string metrics
+		}
+
+		m, ok := index[smp.Class]
+		if !ok {
+			m = &otlpMetric{sample: smp}
+			index[smp.Class] = m
+			metrics = append(metrics, m)
+		}
+
+		point := otlpPoint{Sample: smp}
+		if smp.Kind == core.Counter {
+			point.start = s.startTime(smp, starts)
+		}
+		m.points = append(m.points, point)
+	}
+	if len(metrics) == 0 {
+		return nil
+	}
+
+	var p protoBuffer
+	p.message(1, func(rm *protoBuffer) {
+		rm.message(1, func(r *protoBuffer) {
+			for _, k := range s.opts.Resource.Keys() {
+				r.message(1, func(kv *protoBuffer) {
+					otlpKeyValue(kv, k, s.opts.Resource[k])
+				})
+			}
+		})
+
+		rm.message(2, func(sm *protoBuffer) {
+			sm.message(1, func(scope *protoBuffer) {
+				scope.string(1, "github.com/ovh/noderig")
+				scope.string(2, s.opts.Version)
+			})
+
+			for _, m := range metrics {
+				sm.message(2, func(mm *protoBuffer) {
+					s.encodeMetric(mm, m)
+				})
+			}
+		})
+	})
+
+	return p.b
+}
+
+func (s *OTLP) encodeMetric(p *protoBuffer, m *otlpMetric) {
+	p.string(1, m.sample.Class)
+	if m.sample.Help != "" {
+		p.string(2, m.sample.Help)
+	}
+	if unit := otlpUnit(m.sample.Unit); unit != "" {
+		p.string(3, unit)
+	}
+
+	points := func(data *protoBuffer) {
+		for _, smp := range m.points {
+			data.message(1, func(dp *protoBuffer) {
+				s.encodeDataPoint(dp, smp)
+			})
+		}
+	}
+
+	if m.sample.Kind == core.Counter {
+		p.message(7, func(sum *protoBuffer) {
+			points(sum)
+			sum.uint64(2, otlpCumulative)
+			sum.uint64(3, 1) // monotonic
+		})
+		return
+	}
+	p.message(5, points)
+}
+
+func (s *OTLP) encodeDataPoint(p *protoBuffer, smp otlpPoint) {
+	if smp.start > 0 {
+		p.fixed64(2, uint64(smp.start))
+	}
+	p.fixed64(3, uint64(smp.Timestamp*1000))
+
+	switch v := smp.Value.(type) {
+	case int64:
+		p.fixed64(6, uint64(v))
+	case uint64:
+		p.fixed64(6, v)
+	default:
+		f, _ := smp.Float()
+		p.double(4, f)
+	}
+
+	attrs := smp.Attributes.Copy()
+	for k, v := range smp.Labels {
+		attrs[k] = v
+	}
+	for _, k := range attrs.Keys() {
+		if rv, ok := s.opts.Resource[k]; ok && rv == attrs[k] {
+			continue // already set on the resource
+		}
+		p.message(7, func(kv *protoBuffer) {
+			otlpKeyValue(kv, k, attrs[k])
+		})
+	}
+}
+
+func otlpKeyValue(p *protoBuffer, key string, value string) {
+	p.string(1, key)
+	p.message(2, func(v *protoBuffer) {
+		v.string(1, value)
+	})
+}
+
+// otlpUnit returns the UCUM unit of the collected values
+func otlpUnit(u core.Unit) string {
+	if u.PerSecond {
+		u.Name, u.PerSecond = strings.TrimSuffix(u.Name, "_per_second"), false
+		if unit := otlpUnit(u); unit != "" {
+			return unit + "/s"
+		}
+		return ""
+	}
+
+	switch u {
+	case core.Bytes:
+		return "By"
+	case core.Percent:
+		return "%"
+	case core.Milliseconds:
+		return "ms"
+	case core.Microseconds:
+		return "us"
+	case core.Celsius:
+		return "Cel"
+	case core.Hertz:
+		return "Hz"
+	case core.Volts:
+		return "V"
+	case core.Watts:
+		return "W"
+	default:
+		return u.Name
+	}
+}
@@ -0,0 +1,127 @@
+package sinks
+
+import (
+	"testing"
+
+	"github.com/ovh/noderig/core"
+)
+
+// otlpStartTimes decodes the start times of the sums data points of an
+// ExportMetricsServiceRequest message.
+func otlpStartTimes(t *testing.T, b []byte) []uint64 {
+	var res []uint64
+	for _, rm := range decodeProto(t, b) {
+		for _, sm := range decodeProto(t, rm.bytes) {
+			if sm.num != 2 {
+				continue
+			}
+			for _, m := range decodeProto(t, sm.bytes) {
+				if m.num != 2 {
+					continue
+				}
+				for _, sum := range decodeProto(t, m.bytes) {
+					if sum.num != 7 {
+						continue
+					}
+					for _, dp := range decodeProto(t, sum.bytes) {
+						if dp.num != 1 {
+							continue
+						}
+						var start uint64
+						for _, f := range decodeProto(t, dp.bytes) {
+							if f.num == 2 {
+								start = f.value
+							}
+						}
+						res = append(res, start)
+					}
+				}
+			}
+		}
+	}
+	return res
+}
+
+func TestOTLPStartTime(t *testing.T) {
+	s, err := NewOTLP(OTLPOptions{Endpoint: "localhost:4318", Protocol: "http"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	eth0 := core.Labels{"iface": "eth0"}
+	eth1 := core.Labels{"iface": "eth1"}
+	pushes := []struct {
+		name    string
+		samples []core.Sample
+		want    []uint64
+	}{
+		{
+			"first seen",
+			[]core.Sample{
+				core.NewCounter(1000, "os.net.bytes", eth0, uint64(10)),
+				core.NewGauge(1000, "os.load1", nil, 1.0),
+			},
+			[]uint64{1000000},
+		},
+		{
+			"new series",
+			[]core.Sample{
+				core.NewCounter(2000, "os.net.bytes", eth0, uint64(20)),
+				core.NewCounter(2000, "os.net.bytes", eth1, uint64(5)),
+			},
+			[]uint64{1000000, 2000000},
+		},
+		{
+			"reset",
+			[]core.Sample{
+				core.NewCounter(3000, "os.net.bytes", eth0, uint64(3)),
+				core.NewCounter(3000, "os.net.bytes", eth1, uint64(5)),
+			},
+			[]uint64{3000000, 2000000},
+		},
+		{
+			"after reset",
+			[]core.Sample{core.NewCounter(4000, "os.net.bytes", eth0, uint64(4))},
+			[]uint64{3000000},
+		},
+		{
+			"vanished series forgotten",
+			[]core.Sample{core.NewCounter(5000, "os.net.bytes", eth1, uint64(6))},
+			[]uint64{5000000},
+		},
+	}
+
+	for _, p := range pushes {
+		got := otlpStartTimes(t, s.encode(p.samples))
+		if len(got) != len(p.want) {
+			t.Errorf("%s: got start times %v, want %v", p.name, got, p.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != p.want[i] {
+				t.Errorf("%s: got start times %v, want %v", p.name, got, p.want)
+				break
+			}
+		}
+	}
+}
+
+func TestOTLPUnit(t *testing.T) {
+	tests := []struct {
+		unit core.Unit
+		want string
+	}{
+		{core.NoUnit, ""},
+		{core.Bytes, "By"},
+		{core.Milliseconds, "ms"},
+		{core.Bytes.Rate(), "By/s"},
+		{core.Milliseconds.Rate(), "ms/s"},
+		{core.NoUnit.Rate(), ""},
+	}
+
+	for _, tt := range tests {
+		if got := otlpUnit(tt.unit); got != tt.want {
+			t.Errorf("otlpUnit(%+v) = %q, want %q", tt.unit, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package sinks
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// Protocol buffers wire types
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+)
+
+// protoBuffer is a minimal protocol buffers encoder, enough to build the
+// few messages pushed by the sinks without generated code.
+type protoBuffer struct {
+	b []byte
+}
+
+func (p *protoBuffer) varint(v uint64) {
+	for v >= 0x80 {
+		p.b = append(p.b, byte(v)|0x80)
+		v >>= 7
+	}
+	p.b = append(p.b, byte(v))
+}
+
+func (p *protoBuffer) tag(field int, wire int) {
+	p.varint(uint64(field)<<3 | uint64(wire))
+}
+
+func (p *protoBuffer) uint64(field int, v uint64) {
+	p.tag(field, wireVarint)
+	p.varint(v)
+}
+
+func (p *protoBuffer) int64(field int, v int64) {
+	p.uint64(field, uint64(v))
+}
+
+func (p *protoBuffer) fixed64(field int, v uint64) {
+	p.tag(field, wireFixed64)
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], v)
+	p.b = append(p.b, buf[:]...)
+}
+
+func (p *protoBuffer) double(field int, v float64) {
+	p.fixed64(field, math.Float64bits(v))
+}
+
+func (p *protoBuffer) bytes(field int, v []byte) {
+	p.tag(field, wireBytes)
+	p.varint(uint64(len(v)))
+	p.b = append(p.b, v...)
+}
+
+func (p *protoBuffer) string(field int, v string) {
+	p.tag(field, wireBytes)
+	p.varint(uint64(len(v)))
+	p.b = append(p.b, v...)
+}
+
+// message encodes a nested message
+func (p *protoBuffer) message(field int, fn func(m *protoBuffer)) {
+	var m protoBuffer
+	fn(&m)
+	p.bytes(field, m.b)
+}
@@ -0,0 +1,221 @@
+package sinks
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/golang/snappy"
+
+	"github.com/ovh/noderig/core"
+)
+
+// RemoteWriteOptions configures the Prometheus remote_write sink
+type RemoteWriteOptions struct {
+	URL       string // remote_write receiver URL
+	Username  string // basic auth user
+	Password  string // basic auth password
+	Bearer    string // bearer token, exclusive with basic auth
+	BatchSize int    // maximum number of samples per request
+	Timeout   uint   // request timeout (ms)
+	Retries   int    // retries before dropping a batch
+	Backoff   uint   // first retry delay (ms), doubled on each retry
+	MaxWait   uint   // maximum retry delay (ms)
+}
+
+// RemoteWrite pushes samples with the Prometheus remote_write protocol (v1)
+type RemoteWrite struct {
+	opts   RemoteWriteOptions
+	client *http.Client
+	retry  retry
+}
+
+// NewRemoteWrite returns an initialized Prometheus remote_write sink.
+func NewRemoteWrite(opts RemoteWriteOptions) (*RemoteWrite, error) {
+	if opts.URL == "" {
+		return nil, fmt.Errorf("remote_write url is required")
+	}
+	if opts.Bearer != "" && opts.Username != "" {
+		return nil, fmt.Errorf("remote_write basic auth and bearer token are exclusive")
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 500
+	}
+
+	return &RemoteWrite{
+		opts:   opts,
+		client: &http.Client{Timeout: time.Duration(opts.Timeout) * time.Millisecond},
+		retry:  newRetry(opts.Retries, opts.Backoff, opts.MaxWait),
+	}, nil
+}
+
+// Push sends samples to the remote_write receiver, in batches.
+func (s *RemoteWrite) Push(samples []core.Sample) error {
+	series := toTimeSeries(samples)
+
+	var err error
+	for len(series) > 0 {
+		var batch []*timeSeries
+		batch, series = nextBatch(series, s.opts.BatchSize)
+
+		payload := snappy.Encode(nil, encodeWriteRequest(batch, samples))
+		if perr := s.retry.do("RemoteWrite", func() error {
+			return s.send(payload)
+		}); perr != nil {
+			err = perr
+		}
+	}
+	return err
+}
+
+func (s *RemoteWrite) send(payload []byte) error {
+	req, err := http.NewRequest("POST", s.opts.URL, bytes.NewReader(payload))
+	if err != nil {
+		return permanent(err)
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	req.Header.Set("User-Agent", "noderig")
+
+	if s.opts.Username != "" {
+		req.SetBasicAuth(s.opts.Username, s.opts.Password)
+	} else if s.opts.Bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+s.opts.Bearer)
+	}
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	return checkResponse(res)
+}
+
+type promLabel struct {
+	name, value string
+}
+
+type promPoint struct {
+	value     float64
+	timestamp int64
+}
+
+type timeSeries struct {
+	name   string
+	labels []promLabel
+	points []promPoint
+}
+
+// toTimeSeries groups the samples by series, labels and points being sorted
+// as expected by the receivers.
+func toTimeSeries(samples []core.Sample) []*timeSeries {
+	var res []*timeSeries
+	index := make(map[string]*timeSeries)
+
+	for _, s := range samples {
+		labels := s.Labels
+		value, ok := s.Float()
+		if !ok {
+			labels = s.Labels.Copy()
+			labels["value"] = fmt.Sprintf("%v", s.Value)
+			value = 1
+		}
+
+		name := core.PrometheusName(s.Class)
+		ts := &timeSeries{name: name, labels: []promLabel{{name: "__name__", value: name}}}
+		for _, k := range labels.Keys() {
+			ts.labels = append(ts.labels, promLabel{name: core.PrometheusLabelName(k), value: labels[k]})
+		}
+		sort.Slice(ts.labels, func(i, j int) bool {
+			return ts.labels[i].name < ts.labels[j].name
+		})
+
+		var key bytes.Buffer
+		for _, l := range ts.labels {
+			key.WriteString(l.name + "\xff" + l.value + "\xff")
+		}
+
+		if prev, ok := index[key.String()]; ok {
+			ts = prev
+		} else {
+			index[key.String()] = ts
+			res = append(res, ts)
+		}
+		ts.points = append(ts.points, promPoint{value: value, timestamp: s.Timestamp / 1000})
+	}
+
+	for _, ts := range res {
+		points := ts.points
+		sort.SliceStable(points, func(i, j int) bool {
+			return points[i].timestamp < points[j].timestamp
+		})
+	}
+	return res
+}
+
+// nextBatch splits the series holding up to size points
+func nextBatch(series []*timeSeries, size int) ([]*timeSeries, []*timeSeries) {
+	count := 0
+	for i, ts := range series {
+		count += len(ts.points)
+		if count > size && i > 0 {
+			return series[:i], series[i:]
+		}
+	}
+	return series, nil
+}
+
+// Remote write metadata metric types
+var remoteWriteTypes = map[core.Kind]uint64{
+	core.Counter: 1,
+	core.Gauge:   2,
+}
+
+// encodeWriteRequest builds a prometheus.WriteRequest message
+func encodeWriteRequest(series []*timeSeries, samples []core.Sample) []byte {
+	var p protoBuffer
+	for _, ts := range series {
+		p.message(1, func(m *protoBuffer) {
+			for _, l := range ts.labels {
+				m.message(1, func(lm *protoBuffer) {
+					lm.string(1, l.name)
+					lm.string(2, l.value)
+				})
+			}
+			for _, pt := range ts.points {
+				m.message(2, func(sm *protoBuffer) {
+					sm.double(1, pt.value)
+					sm.int64(2, pt.timestamp)
+				})
+			}
+		})
+	}
+
+	families := make(map[string]bool)
+	for _, ts := range series {
+		families[ts.name] = true
+	}
+
+	seen := make(map[string]bool)
+	for _, s := range samples {
+		name := core.PrometheusName(s.Class)
+		if !families[name] || seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		p.message(3, func(m *protoBuffer) {
+			m.uint64(1, remoteWriteTypes[s.Kind])
+			m.string(2, name)
+			if s.Help != "" {
+				m.string(4, s.Help)
+			}
+		})
+	}
+
+	return p.b
+}
@@ -0,0 +1,266 @@
+package sinks
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/golang/snappy"
+
+	"github.com/ovh/noderig/core"
+)
+
+type protoField struct {
+	num   int
+	value uint64
+	bytes []byte
+}
+
+// decodeProto splits a protocol buffers message into its fields
+func decodeProto(t *testing.T, b []byte) []protoField {
+	t.Helper()
+
+	var fields []protoField
+	for len(b) > 0 {
+		tag, n := binary.Uvarint(b)
+		if n <= 0 {
+			t.Fatalf("invalid tag")
+		}
+		b = b[n:]
+
+		f := protoField{num: int(tag >> 3)}
+		switch tag & 7 {
+		case wireVarint:
+			f.value, n = binary.Uvarint(b)
+			b = b[n:]
+		case wireFixed64:
+			f.value = binary.LittleEndian.Uint64(b)
+			b = b[8:]
+		case wireBytes:
+			l, n := binary.Uvarint(b)
+			f.bytes = b[n : n+int(l)]
+			b = b[n+int(l):]
+		default:
+			t.Fatalf("unexpected wire type %d", tag&7)
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+type receivedSeries struct {
+	labels map[string]string
+	points []promPoint
+}
+
+type receivedRequest struct {
+	series   []receivedSeries
+	metadata []string
+}
+
+// decodeWriteRequest decodes a prometheus.WriteRequest message
+func decodeWriteRequest(t *testing.T, b []byte) receivedRequest {
+	var req receivedRequest
+	for _, f := range decodeProto(t, b) {
+		switch f.num {
+		case 1:
+			rs := receivedSeries{labels: make(map[string]string)}
+			for _, sf := range decodeProto(t, f.bytes) {
+				switch sf.num {
+				case 1:
+					l := decodeProto(t, sf.bytes)
+					rs.labels[string(l[0].bytes)] = string(l[1].bytes)
+				case 2:
+					var pt promPoint
+					for _, pf := range decodeProto(t, sf.bytes) {
+						if pf.num == 1 {
+							pt.value = math.Float64frombits(pf.value)
+						} else {
+							pt.timestamp = int64(pf.value)
+						}
+					}
+					rs.points = append(rs.points, pt)
+				}
+			}
+			req.series = append(req.series, rs)
+		case 3:
+			for _, mf := range decodeProto(t, f.bytes) {
+				if mf.num == 2 {
+					req.metadata = append(req.metadata, string(mf.bytes))
+				}
+			}
+		}
+	}
+	return req
+}
+
+// remoteWriteReceiver records the requests, answering with the given status
+// codes in turn, then 204.
+type remoteWriteReceiver struct {
+	t        *testing.T
+	mutex    sync.Mutex
+	statuses []int
+	requests []receivedRequest
+	headers  []http.Header
+}
+
+func (rcv *remoteWriteReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rcv.mutex.Lock()
+	defer rcv.mutex.Unlock()
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		rcv.t.Errorf("read body: %v", err)
+	}
+	payload, err := snappy.Decode(nil, body)
+	if err != nil {
+		rcv.t.Errorf("snappy decode: %v", err)
+	}
+
+	rcv.headers = append(rcv.headers, r.Header)
+	rcv.requests = append(rcv.requests, decodeWriteRequest(rcv.t, payload))
+
+	if len(rcv.statuses) > 0 {
+		w.WriteHeader(rcv.statuses[0])
+		rcv.statuses = rcv.statuses[1:]
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func TestRemoteWritePush(t *testing.T) {
+	rcv := &remoteWriteReceiver{t: t}
+	srv := httptest.NewServer(rcv)
+	defer srv.Close()
+
+	s, err := NewRemoteWrite(RemoteWriteOptions{URL: srv.URL, Username: "user", Password: "pass"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sent := core.NewCounter(2000000, "os.net.bytes", core.Labels{"iface": "eth0", "direction": "in"}, uint64(10))
+	sent.Help = "Network bytes"
+	err = s.Push([]core.Sample{
+		sent,
+		core.NewCounter(1000000, "os.net.bytes", core.Labels{"iface": "eth0", "direction": "in"}, uint64(5)),
+		core.NewGauge(1000000, "os.systemd.unit.state", core.Labels{"unit": "nginx.service"}, "active"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rcv.requests) != 1 {
+		t.Fatalf("received %d requests, want 1", len(rcv.requests))
+	}
+
+	h := rcv.headers[0]
+	for k, want := range map[string]string{
+		"Content-Type":                      "application/x-protobuf",
+		"Content-Encoding":                  "snappy",
+		"X-Prometheus-Remote-Write-Version": "0.1.0",
+	} {
+		if got := h.Get(k); got != want {
+			t.Errorf("header %s = %q, want %q", k, got, want)
+		}
+	}
+	if user, pass, ok := (&http.Request{Header: h}).BasicAuth(); !ok || user != "user" || pass != "pass" {
+		t.Errorf("basic auth = %q, %q, %v", user, pass, ok)
+	}
+
+	want := receivedRequest{
+		series: []receivedSeries{
+			{
+				labels: map[string]string{"__name__": "os_net_bytes", "direction": "in", "iface": "eth0"},
+				points: []promPoint{{value: 5, timestamp: 1000}, {value: 10, timestamp: 2000}},
+			},
+			{
+				labels: map[string]string{"__name__": "os_systemd_unit_state", "unit": "nginx.service", "value": "active"},
+				points: []promPoint{{value: 1, timestamp: 1000}},
+			},
+		},
+		metadata: []string{"os_net_bytes", "os_systemd_unit_state"},
+	}
+	if got := rcv.requests[0]; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestRemoteWriteBatches(t *testing.T) {
+	rcv := &remoteWriteReceiver{t: t}
+	srv := httptest.NewServer(rcv)
+	defer srv.Close()
+
+	s, err := NewRemoteWrite(RemoteWriteOptions{URL: srv.URL, BatchSize: 2, Bearer: "token"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.Push([]core.Sample{
+		core.NewGauge(1000, "os.load1", nil, 1.0),
+		core.NewGauge(2000, "os.load1", nil, 2.0),
+		core.NewGauge(1000, "os.load5", nil, 3.0),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rcv.requests) != 2 {
+		t.Fatalf("received %d requests, want 2", len(rcv.requests))
+	}
+	for i, name := range []string{"os_load1", "os_load5"} {
+		req := rcv.requests[i]
+		if len(req.series) != 1 || req.series[0].labels["__name__"] != name {
+			t.Errorf("request %d: got %+v, want %s series", i, req, name)
+		}
+		if got := rcv.headers[i].Get("Authorization"); got != "Bearer token" {
+			t.Errorf("request %d: authorization = %q", i, got)
+		}
+	}
+}
+
+func TestRemoteWriteRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		requests int
+		fail     bool
+	}{
+		{"server error retried", []int{http.StatusInternalServerError}, 2, false},
+		{"throttling retried", []int{http.StatusTooManyRequests}, 2, false},
+		{"client error dropped", []int{http.StatusBadRequest}, 1, true},
+		{"retries exhausted", []int{500, 500, 500}, 2, true},
+	}
+
+	for _, tt := range tests {
+		rcv := &remoteWriteReceiver{t: t, statuses: tt.statuses}
+		srv := httptest.NewServer(rcv)
+
+		s, err := NewRemoteWrite(RemoteWriteOptions{URL: srv.URL, Retries: 1, Backoff: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = s.Push([]core.Sample{core.NewGauge(1000, "os.load1", nil, 1.0)})
+		if (err != nil) != tt.fail {
+			t.Errorf("%s: push error = %v, want failure %v", tt.name, err, tt.fail)
+		}
+		if len(rcv.requests) != tt.requests {
+			t.Errorf("%s: received %d requests, want %d", tt.name, len(rcv.requests), tt.requests)
+		}
+		srv.Close()
+	}
+}
+
+func TestNewRemoteWriteOptions(t *testing.T) {
+	if _, err := NewRemoteWrite(RemoteWriteOptions{}); err == nil {
+		t.Error("missing url accepted")
+	}
+	if _, err := NewRemoteWrite(RemoteWriteOptions{URL: "http://localhost", Username: "u", Bearer: "t"}); err == nil {
+		t.Error("basic auth and bearer token accepted together")
+	}
+}
@@ -0,0 +1,60 @@
+package sinks
+
+import (
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// permanentError is an error that must not be retried
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string {
+	return e.err.Error()
+}
+
+// permanent flags an error as not worth retrying
+func permanent(err error) error {
+	return permanentError{err: err}
+}
+
+func isPermanent(err error) bool {
+	_, ok := err.(permanentError)
+	return ok
+}
+
+// retry runs an operation with an exponential backoff between attempts
+type retry struct {
+	retries int
+	backoff time.Duration
+	max     time.Duration
+}
+
+func newRetry(retries int, backoff uint, max uint) retry {
+	return retry{
+		retries: retries,
+		backoff: time.Duration(backoff) * time.Millisecond,
+		max:     time.Duration(max) * time.Millisecond,
+	}
+}
+
+func (r retry) do(name string, fn func() error) error {
+	wait := r.backoff
+
+	var err error
+	for attempt := 0; ; attempt++ {
+		if err = fn(); err == nil || isPermanent(err) || attempt >= r.retries {
+			return err
+		}
+
+		log.WithError(err).Debugf("[%s] attempt %d failed, retry in %v", name, attempt+1, wait)
+		time.Sleep(wait)
+
+		wait *= 2
+		if r.max > 0 && wait > r.max {
+			wait = r.max
+		}
+	}
+}
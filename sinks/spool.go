@@ -0,0 +1,106 @@
+package sinks
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// spool keeps the batches that could not be delivered on disk, to send them
+// again once the backend is back.
+type spool struct {
+	mutex sync.Mutex
+	dir   string
+	max   int
+}
+
+const spoolExt = ".batch"
+
+func newSpool(dir string, max int) (*spool, error) {
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, err
+	}
+	return &spool{dir: dir, max: max}, nil
+}
+
+// store writes a batch into the spool, dropping the oldest ones above the spool size
+func (s *spool) store(payload []byte) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	name := path.Join(s.dir, fmt.Sprintf("%020d%s", time.Now().UnixNano(), spoolExt))
+	if err := ioutil.WriteFile(name+".tmp", payload, 0640); err != nil {
+		return err
+	}
+	if err := os.Rename(name+".tmp", name); err != nil {
+		return err
+	}
+
+	files, err := s.files()
+	if err != nil {
+		return err
+	}
+	if s.max > 0 && len(files) > s.max {
+		for _, f := range files[:len(files)-s.max] {
+			log.Warnf("Spool %s is full, drop %s", s.dir, f)
+			if err := os.Remove(path.Join(s.dir, f)); err != nil {
+				log.WithError(err).Error("Cannot remove spooled batch")
+			}
+		}
+	}
+	return nil
+}
+
+// replay sends the spooled batches, oldest first, and stops on the first failure
+func (s *spool) replay(send func([]byte) error) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	files, err := s.files()
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		name := path.Join(s.dir, f)
+		payload, err := ioutil.ReadFile(name)
+		if err != nil {
+			return err
+		}
+
+		if err := send(payload); err != nil {
+			if !isPermanent(err) {
+				return err
+			}
+			log.WithError(err).Errorf("Drop spooled batch %s", name)
+		}
+
+		if err := os.Remove(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *spool) files() ([]string, error) {
+	infos, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []string
+	for _, info := range infos {
+		if strings.HasSuffix(info.Name(), spoolExt) {
+			res = append(res, info.Name())
+		}
+	}
+	sort.Strings(res)
+	return res, nil
+}
@@ -0,0 +1,159 @@
+package sinks
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ovh/noderig/core"
+)
+
+// StatsdOptions configures the StatsD sink
+type StatsdOptions struct {
+	Network    string // udp or unixgram
+	Address    string // host:port or socket path
+	Flavor     string // dogstatsd (tags) or statsd (labels values appended to the name)
+	Prefix     string // metric names prefix
+	PacketSize int    // maximum datagram size
+	Timeout    uint   // write timeout (ms)
+}
+
+// Statsd emits samples as StatsD/DogStatsD datagrams.
+//
+// Gauges are sent as is. Counters are cumulative in Noderig while StatsD
+// counters are increments, so the difference with the previously pushed
+// value is sent.
+type Statsd struct {
+	opts StatsdOptions
+
+	mutex    sync.Mutex
+	conn     net.Conn
+	previous map[string]float64
+}
+
+// NewStatsd returns an initialized StatsD sink.
+func NewStatsd(opts StatsdOptions) (*Statsd, error) {
+	if opts.Address == "" {
+		return nil, fmt.Errorf("statsd address is required")
+	}
+	if opts.Network != "udp" && opts.Network != "unixgram" {
+		return nil, fmt.Errorf("statsd network must be udp or unixgram, got '%s'", opts.Network)
+	}
+	if opts.Flavor != "dogstatsd" && opts.Flavor != "statsd" {
+		return nil, fmt.Errorf("statsd flavor must be dogstatsd or statsd, got '%s'", opts.Flavor)
+	}
+	if opts.PacketSize <= 0 {
+		opts.PacketSize = 1432
+	}
+
+	return &Statsd{
+		opts:     opts,
+		previous: make(map[string]float64),
+	}, nil
+}
+
+// Push sends samples as datagrams, several metrics being packed per datagram.
+func (s *Statsd) Push(samples []core.Sample) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// Only keep the counters of this push, so that vanished series are evicted
+	previous := make(map[string]float64, len(s.previous))
+	var lines []string
+	for _, smp := range samples {
+		if line, ok := s.line(smp, previous); ok {
+			lines = append(lines, line)
+		}
+	}
+	s.previous = previous
+
+	var buf bytes.Buffer
+	for _, line := range lines {
+		if buf.Len() > 0 && buf.Len()+len(line)+1 > s.opts.PacketSize {
+			if err := s.write(buf.Bytes()); err != nil {
+				return err
+			}
+			buf.Reset()
+		}
+		if buf.Len() > 0 {
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(line)
+	}
+
+	if buf.Len() == 0 {
+		return nil
+	}
+	return s.write(buf.Bytes())
+}
+
+func (s *Statsd) line(smp core.Sample, previous map[string]float64) (string, bool) {
+	value, ok := smp.Float()
+	if !ok || math.IsNaN(value) || math.IsInf(value, 0) {
+		return "", false // statsd only handles numbers
+	}
+
+	name := s.opts.Prefix + statsdReplacer.Replace(smp.Class)
+	var tags []string
+	for _, k := range smp.Labels.Keys() {
+		if s.opts.Flavor == "dogstatsd" {
+			tags = append(tags, statsdReplacer.Replace(k)+":"+statsdReplacer.Replace(smp.Labels[k]))
+		} else {
+			name += "." + statsdReplacer.Replace(smp.Labels[k])
+		}
+	}
+
+	metricType := "g"
+	if smp.Kind == core.Counter {
+		key := name + "|" + strings.Join(tags, ",")
+		current := value
+		prev, seen := s.previous[key]
+		previous[key] = current
+		if !seen {
+			return "", false
+		}
+
+		metricType = "c"
+		value = current - prev
+		if value < 0 {
+			value = current // counter reset
+		}
+	}
+
+	line := name + ":" + strconv.FormatFloat(value, 'f', -1, 64) + "|" + metricType
+	if len(tags) > 0 {
+		line += "|#" + strings.Join(tags, ",")
+	}
+	return line, true
+}
+
+// statsd reserved characters
+var statsdReplacer = strings.NewReplacer(":", "_", "|", "_", "@", "_", "#", "_", ",", "_", "\n", "_", " ", "_")
+
+func (s *Statsd) write(payload []byte) error {
+	if s.conn == nil {
+		conn, err := net.Dial(s.opts.Network, s.opts.Address)
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+	}
+
+	if s.opts.Timeout > 0 {
+		if err := s.conn.SetWriteDeadline(time.Now().Add(time.Duration(s.opts.Timeout) * time.Millisecond)); err != nil {
+			return err
+		}
+	}
+
+	if _, err := s.conn.Write(payload); err != nil {
+		_ = s.conn.Close()
+		s.conn = nil
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package sinks
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ovh/noderig/core"
+)
+
+func TestStatsdPush(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	s, err := NewStatsd(StatsdOptions{Network: "udp", Address: conn.LocalAddr().String(), Flavor: "dogstatsd", Prefix: "n."})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	read := func() string {
+		buf := make([]byte, 2048)
+		_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(buf[:n])
+	}
+
+	eth0 := core.Labels{"iface": "eth0"}
+	eth1 := core.Labels{"iface": "eth1"}
+	pushes := []struct {
+		samples  []core.Sample
+		want     string
+		previous int
+	}{
+		{
+			[]core.Sample{
+				core.NewGauge(1, "os.load1", nil, 0.5),
+				core.NewCounter(1, "os.net.bytes", eth0, uint64(10)),
+				core.NewCounter(1, "os.net.bytes", eth1, uint64(10)),
+			},
+			"n.os.load1:0.5|g",
+			2,
+		},
+		{
+			// eth1 vanishes, its last value is evicted
+			[]core.Sample{core.NewCounter(2, "os.net.bytes", eth0, uint64(15))},
+			"n.os.net.bytes:5|c|#iface:eth0",
+			1,
+		},
+		{
+			// eth1 comes back, seen as a new series
+			[]core.Sample{
+				core.NewCounter(3, "os.net.bytes", eth0, uint64(3)),
+				core.NewCounter(3, "os.net.bytes", eth1, uint64(30)),
+			},
+			"n.os.net.bytes:3|c|#iface:eth0",
+			2,
+		},
+	}
+
+	for i, p := range pushes {
+		if err := s.Push(p.samples); err != nil {
+			t.Fatal(err)
+		}
+		if got := read(); got != p.want {
+			t.Errorf("push %d: got %q, want %q", i, got, p.want)
+		}
+		if len(s.previous) != p.previous {
+			t.Errorf("push %d: previous values %v, want %d", i, s.previous, p.previous)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package sinks
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ovh/noderig/core"
+	log "github.com/sirupsen/logrus"
+)
+
+// Warp10Options configures the Warp10 sink
+type Warp10Options struct {
+	Endpoint  string // Warp10 update endpoint, e.g. https://warp10.example/api/v0/update
+	Token     string // Warp10 write token
+	Gzip      bool   // compress batches
+	Timeout   uint   // request timeout (ms)
+	Retries   int    // retries before spooling a batch
+	Backoff   uint   // first retry delay (ms), doubled on each retry
+	MaxWait   uint   // maximum retry delay (ms)
+	Spool     string // spool directory, no spool when empty
+	SpoolSize int    // maximum number of spooled batches, 0 for unlimited
+}
+
+// Warp10 pushes samples as Sensision to a Warp10 update endpoint
+type Warp10 struct {
+	opts    Warp10Options
+	client  *http.Client
+	retry   retry
+	spool   *spool
+	encoder core.SensisionEncoder
+}
+
+// NewWarp10 returns an initialized Warp10 sink.
+func NewWarp10(opts Warp10Options) (*Warp10, error) {
+	if opts.Endpoint == "" {
+		return nil, fmt.Errorf("warp10 endpoint is required")
+	}
+
+	s := &Warp10{
+		opts:   opts,
+		client: &http.Client{Timeout: time.Duration(opts.Timeout) * time.Millisecond},
+		retry:  newRetry(opts.Retries, opts.Backoff, opts.MaxWait),
+	}
+
+	if opts.Spool != "" {
+		sp, err := newSpool(opts.Spool, opts.SpoolSize)
+		if err != nil {
+			return nil, err
+		}
+		s.spool = sp
+	}
+
+	return s, nil
+}
+
+// Push sends samples to Warp10, then the spooled batches once the endpoint accepts data again.
+func (s *Warp10) Push(samples []core.Sample) error {
+	if len(samples) == 0 {
+		return nil
+	}
+
+	var payload bytes.Buffer
+	if err := s.encoder.Encode(&payload, samples); err != nil {
+		return err
+	}
+
+	err := s.retry.do("Warp10", func() error {
+		return s.send(payload.Bytes())
+	})
+	if err != nil {
+		if s.spool != nil && !isPermanent(err) {
+			if serr := s.spool.store(payload.Bytes()); serr != nil {
+				log.WithError(serr).Error("[Warp10] cannot spool batch")
+			}
+		}
+		return err
+	}
+
+	if s.spool != nil {
+		return s.spool.replay(s.send)
+	}
+	return nil
+}
+
+func (s *Warp10) send(payload []byte) error {
+	body := payload
+	contentType := "text/plain"
+
+	if s.opts.Gzip {
+		var err error
+		if body, err = gzipPayload(payload); err != nil {
+			return err
+		}
+		contentType = "application/gzip"
+	}
+
+	req, err := http.NewRequest("POST", s.opts.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return permanent(err)
+	}
+	req.Header.Set("X-Warp10-Token", s.opts.Token)
+	req.Header.Set("Content-Type", contentType)
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	return checkResponse(res)
+}
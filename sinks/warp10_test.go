@@ -0,0 +1,217 @@
+package sinks
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/ovh/noderig/core"
+)
+
+// warp10Receiver records the pushed Sensision payloads, answering with the
+// given status codes in turn, then 200.
+type warp10Receiver struct {
+	t        *testing.T
+	mutex    sync.Mutex
+	statuses []int
+	payloads []string
+	headers  []http.Header
+}
+
+func (rcv *warp10Receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rcv.mutex.Lock()
+	defer rcv.mutex.Unlock()
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		rcv.t.Errorf("read body: %v", err)
+	}
+	if r.Header.Get("Content-Type") == "application/gzip" {
+		zr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			rcv.t.Fatalf("gzip reader: %v", err)
+		}
+		if body, err = ioutil.ReadAll(zr); err != nil {
+			rcv.t.Errorf("gzip decode: %v", err)
+		}
+	}
+
+	rcv.headers = append(rcv.headers, r.Header)
+	rcv.payloads = append(rcv.payloads, string(body))
+
+	if len(rcv.statuses) > 0 {
+		w.WriteHeader(rcv.statuses[0])
+		rcv.statuses = rcv.statuses[1:]
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func spoolDir(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "noderig-spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+func spooled(t *testing.T, s *Warp10) int {
+	files, err := s.spool.files()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(files)
+}
+
+func load(ts int64) core.Sample {
+	return core.NewGauge(ts, "os.load1", nil, float64(ts))
+}
+
+func TestWarp10Push(t *testing.T) {
+	rcv := &warp10Receiver{t: t}
+	srv := httptest.NewServer(rcv)
+	defer srv.Close()
+
+	s, err := NewWarp10(Warp10Options{Endpoint: srv.URL, Token: "token", Gzip: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	samples := []core.Sample{
+		load(1000),
+		core.NewCounter(1000, "os.net.bytes", core.Labels{"iface": "eth0"}, uint64(10)),
+	}
+	if err := s.Push(samples); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rcv.payloads) != 1 {
+		t.Fatalf("received %d requests, want 1", len(rcv.payloads))
+	}
+	for k, want := range map[string]string{
+		"X-Warp10-Token": "token",
+		"Content-Type":   "application/gzip",
+	} {
+		if got := rcv.headers[0].Get(k); got != want {
+			t.Errorf("header %s = %q, want %q", k, got, want)
+		}
+	}
+	if want := core.ToSensision(samples[0]) + core.ToSensision(samples[1]); rcv.payloads[0] != want {
+		t.Errorf("got payload %q, want %q", rcv.payloads[0], want)
+	}
+}
+
+func TestWarp10Spool(t *testing.T) {
+	dir, cleanup := spoolDir(t)
+	defer cleanup()
+
+	rcv := &warp10Receiver{t: t, statuses: []int{500, 503}}
+	srv := httptest.NewServer(rcv)
+	defer srv.Close()
+
+	s, err := NewWarp10(Warp10Options{Endpoint: srv.URL, Spool: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, ts := range []int64{1, 2} {
+		if err := s.Push([]core.Sample{load(ts)}); err == nil {
+			t.Errorf("push %d: server error not reported", i)
+		}
+		if n := spooled(t, s); n != i+1 {
+			t.Errorf("push %d: %d spooled batches, want %d", i, n, i+1)
+		}
+	}
+
+	// the server is back, the spool is replayed after the fresh batch
+	if err := s.Push([]core.Sample{load(3)}); err != nil {
+		t.Fatal(err)
+	}
+	if n := spooled(t, s); n != 0 {
+		t.Errorf("%d spooled batches left", n)
+	}
+
+	want := []string{
+		core.ToSensision(load(1)),
+		core.ToSensision(load(2)),
+		core.ToSensision(load(3)),
+		core.ToSensision(load(1)),
+		core.ToSensision(load(2)),
+	}
+	if !reflect.DeepEqual(rcv.payloads, want) {
+		t.Errorf("got payloads %q, want %q", rcv.payloads, want)
+	}
+}
+
+func TestWarp10SpoolSize(t *testing.T) {
+	dir, cleanup := spoolDir(t)
+	defer cleanup()
+
+	rcv := &warp10Receiver{t: t, statuses: []int{500, 500, 500}}
+	srv := httptest.NewServer(rcv)
+	defer srv.Close()
+
+	s, err := NewWarp10(Warp10Options{Endpoint: srv.URL, Spool: dir, SpoolSize: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ts := range []int64{1, 2, 3} {
+		_ = s.Push([]core.Sample{load(ts)})
+	}
+	if n := spooled(t, s); n != 2 {
+		t.Errorf("%d spooled batches, want 2", n)
+	}
+
+	rcv.payloads = nil
+	if err := s.Push([]core.Sample{load(4)}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the oldest batch was evicted
+	want := []string{
+		core.ToSensision(load(4)),
+		core.ToSensision(load(2)),
+		core.ToSensision(load(3)),
+	}
+	if !reflect.DeepEqual(rcv.payloads, want) {
+		t.Errorf("got payloads %q, want %q", rcv.payloads, want)
+	}
+}
+
+func TestWarp10ClientError(t *testing.T) {
+	dir, cleanup := spoolDir(t)
+	defer cleanup()
+
+	rcv := &warp10Receiver{t: t, statuses: []int{http.StatusBadRequest}}
+	srv := httptest.NewServer(rcv)
+	defer srv.Close()
+
+	s, err := NewWarp10(Warp10Options{Endpoint: srv.URL, Spool: dir, Retries: 2, Backoff: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.Push([]core.Sample{load(1)})
+	if err == nil || !isPermanent(err) {
+		t.Errorf("push error = %v, want a permanent error", err)
+	}
+	if len(rcv.payloads) != 1 {
+		t.Errorf("received %d requests, want 1", len(rcv.payloads))
+	}
+	if n := spooled(t, s); n != 0 {
+		t.Errorf("%d spooled batches, want none", n)
+	}
+}
+
+func TestNewWarp10Options(t *testing.T) {
+	if _, err := NewWarp10(Warp10Options{}); err == nil {
+		t.Error("missing endpoint accepted")
+	}
+}